@@ -0,0 +1,57 @@
+package file_op
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock
+// @description 时钟接口，所有需要获取当前时间的地方都通过 Clock 获取，
+//				测试时可以替换成 FakeClock 控制时间
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock 系统时钟，默认使用
+var SystemClock Clock = systemClock{}
+
+// FakeClock
+// @description 可以手动控制的时钟，用于编写确定性的测试
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now
+// @description 获取当前时间
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set
+// @description 设置当前时间
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Add
+// @description 让时间向前推进 d
+func (c *FakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
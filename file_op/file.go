@@ -1,9 +1,13 @@
 package file_op
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,11 +15,38 @@ import (
 
 type FileOp struct {
 	file         *os.File
-	isOpen       bool // 用于判断是否可以进行操作
-	needCompress bool // 是否需要压缩
-	maxSize      int  // 以 MB 为单位
+	isOpen       bool   // 用于判断是否可以进行操作
+	needCompress bool   // 是否需要压缩
+	maxSize      int    // 以 MB 为单位
+	maxBackups   int    // 最多保留的历史日志个数，0 表示不限制
+	maxAge       int    // 历史日志最多保留天数，0 表示不限制
+	maxTotalSize int    // 当前日志和历史日志的总容量，以 MB 为单位，0 表示不限制
+	quota        *Quota // 和其他日志共享的总容量限制，设置后 maxTotalSize 不再生效
+	maxLines     int    // 单个日志最多的行数，0 表示不限制
+	daily        bool   // 是否按日期切分，跨天后写入前切分
 	curDate      time.Time
 	path         string
+	clock        Clock // 时钟，用于按日期切分日志和清理历史日志
+
+	checkInterval time.Duration // 检查日志文件是否被移动或者删除的间隔，0 表示不检查
+	lastCheck     time.Time     // 上一次检查的时间
+
+	layout Layout // 日志文件布局
+	active string // Symlink 布局下当前日志的实际路径
+	size   int64  // 当前日志的大小，打开时读取，之后按照写入的字节数累加
+	lines  int64  // 当前日志的行数，设置 maxLines 时打开文件统计，之后按照写入的换行累加
+
+	template string            // 日志路径模板，为空时 path 固定
+	vars     map[string]string // 路径模板中的变量
+
+	repair   bool                   // 打开已有的日志文件时是否修复末尾不完整的行
+	newline  bool                   // 只在末尾补上换行，不移动不完整的行
+	valid    func(line []byte) bool // 判断最后一行是否完整
+	onRepair RepairFunc             // 修复之后调用
+
+	zips    sync.WaitGroup  // 后台压缩历史日志的协程
+	zipMu   sync.Mutex      // 保护 zipping
+	zipping map[string]bool // 正在压缩的历史日志和压缩包，清理历史日志时跳过
 }
 
 func CreateFileOp(path string, maxSize int, needCompress bool) *FileOp {
@@ -24,92 +55,456 @@ func CreateFileOp(path string, maxSize int, needCompress bool) *FileOp {
 		needCompress: needCompress,
 		isOpen:       false,
 		maxSize:      maxSize,
+		clock:        SystemClock,
 	}
 }
 
+// SetClock
+// @description 设置时钟
+func (fo *FileOp) SetClock(clock Clock) *FileOp {
+	if clock != nil {
+		fo.clock = clock
+	}
+	return fo
+}
+
+// SetMaxBackups
+// @description 设置最多保留的历史日志个数
+func (fo *FileOp) SetMaxBackups(maxBackups int) *FileOp {
+	fo.maxBackups = maxBackups
+	return fo
+}
+
+// SetMaxAge
+// @description 设置历史日志最多保留天数
+func (fo *FileOp) SetMaxAge(maxAge int) *FileOp {
+	fo.maxAge = maxAge
+	return fo
+}
+
+// SetMaxTotalSize
+// @description 设置当前日志和历史日志的总容量 (MB)，超过后从最早的历史日志开始删除
+func (fo *FileOp) SetMaxTotalSize(maxTotalSize int) *FileOp {
+	fo.maxTotalSize = maxTotalSize
+	return fo
+}
+
+// SetMaxLines
+// @description 设置单个日志最多的行数，达到后切分，和 maxSize 任意一个满足即切分
+func (fo *FileOp) SetMaxLines(maxLines int) *FileOp {
+	fo.maxLines = maxLines
+	return fo
+}
+
+// SetRotateDaily
+// @description 设置是否按日期切分，开启后跨天的第一次写入前切分，默认只按照大小和行数切分
+func (fo *FileOp) SetRotateDaily(daily bool) *FileOp {
+	fo.daily = daily
+	return fo
+}
+
+// Path
+// @description 获取当前日志路径，使用路径模板时为最近一次计算的路径
+func (fo *FileOp) Path() string {
+	return fo.path
+}
+
+// SetCheckInterval
+// @description 设置检查日志文件是否被移动或者删除的间隔，例如被 logrotate 改名，
+//				检查到之后重新打开日志文件，0 表示不检查
+func (fo *FileOp) SetCheckInterval(interval time.Duration) *FileOp {
+	fo.checkInterval = interval
+	return fo
+}
+
 // ready
 // @description 用于进行文件操作前的准备工作
 func (fo *FileOp) ready() (err error) {
 	if fo.file == nil {
-		if IsExists(fo.path) {
+		if len(fo.template) != 0 {
+			fo.path = renderPath(fo.template, fo.clock.Now(), fo.vars)
+		}
+		if fo.layout == Layouts.Symlink {
+			fo.file, err = fo.openActive()
+			if err != nil {
+				return err
+			}
+		} else if IsExists(fo.path) {
 			fo.file, err = MustOpenFile(fo.path)
 			if err != nil {
 				return err
 			}
 		} else {
+			if err = mkdirForPath(fo.path); err != nil {
+				return err
+			}
 			fo.file, err = CreateFile(fo.path)
 			if err != nil {
 				return err
 			}
 		}
 	}
+	info, err := fo.file.Stat()
+	if err != nil {
+		return err
+	}
+	fo.size = info.Size()
+	if err = fo.repairTail(); err != nil {
+		return err
+	}
+	if fo.lines, err = fo.countLines(); err != nil {
+		return err
+	}
 	fo.isOpen = true
-	fo.curDate = time.Now()
+	fo.curDate = fo.clock.Now()
+	// 继续写入已有的文件时 (例如程序重启)，按照最后修改时间恢复文件所属的日期，
+	// 开启按日期切分且文件已经过期，或者超过 maxSize 时，写入前会先切分
+	if fo.size > 0 && info.ModTime().Before(fo.curDate) {
+		fo.curDate = info.ModTime()
+	}
+	fo.lastCheck = fo.curDate
 	return nil
 }
 
+// Reopen
+// @description 关闭并重新打开日志文件，文件已经被移动或者删除时创建新的文件
+func (fo *FileOp) Reopen() error {
+	if fo.isOpen {
+		_ = fo.Close()
+	}
+	return fo.ready()
+}
+
+// moved
+// @description 按照 checkInterval 检查路径指向的文件和打开的文件是否相同 (设备号和 inode)，
+//				文件被移动、删除或者替换时返回 true
+func (fo *FileOp) moved() bool {
+	if fo.checkInterval <= 0 {
+		return false
+	}
+	now := fo.clock.Now()
+	if now.Sub(fo.lastCheck) < fo.checkInterval {
+		return false
+	}
+	fo.lastCheck = now
+
+	pathInfo, err := os.Stat(fo.path)
+	if err != nil {
+		return true
+	}
+	fileInfo, err := fo.file.Stat()
+	if err != nil {
+		return true
+	}
+	return !os.SameFile(pathInfo, fileInfo)
+}
+
 // Write
 // @param buf 需要写入的字节
 // @description 这里不做并发控制，由 Logger 传递过来的日志数据是通过 channel 发送过来的，
 //				，并不会出现多个协程往同一个文件里面写数据，文件操作模块主要集中于对日志文
 //				件的分片管理，对历史日志打包
 func (fo *FileOp) Write(buf []byte) error {
+	_, err := fo.write(append(buf, '\n'))
+	return err
+}
+
+// write
+// @description 写入原始字节，不追加换行，写入前判断是否需要切分
+func (fo *FileOp) write(buf []byte) (int, error) {
 	if !fo.isOpen {
-		_ = fo.ready()
+		if err := fo.ready(); err != nil {
+			return 0, err
+		}
+	} else if switched, err := fo.switchPath(); err != nil {
+		return 0, err
+	} else if !switched && fo.moved() {
+		if err := fo.Reopen(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := fo.truncated(); err != nil {
+		return 0, err
 	}
 
-	var wg sync.WaitGroup
-
-	// 判断当前文件是否超出 maxSize
-	// 如果超出了最大限制，则需要进行以下操作:
+	// 判断当前文件是否超出 maxSize 或者已经跨天
+	// 如果需要切分，则需要进行以下操作:
 	// - 断开 fo.file 指针
 	// - 创建新文件，并将 fo.file 指向新的文件
-	// - 将原来的文件压缩打包
-	if fo.overMaxSize() {
-		_ = fo.Close()
+	// - 将原来的文件在后台压缩打包
+	// - 清理过期的历史日志
+	rotated := fo.overMaxSize() || fo.overMaxLines() || fo.overDate()
+	if rotated {
+		if err := fo.archive(); err != nil {
+			return 0, err
+		}
+	}
 
-		now := time.Now()
+	n, err := fo.file.Write(buf)
+	fo.size += int64(n)
+	if fo.maxLines > 0 {
+		fo.lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+	}
 
-		date, month, day := now.Date()
-		timestamp := now.Unix()
+	if rotated {
+		_ = fo.cleanBackups()
+	}
+	return n, err
+}
+
+// Sync
+// @description 将已经写入的日志同步到磁盘 (fsync)，文件没有打开时直接返回
+func (fo *FileOp) Sync() error {
+	if !fo.isOpen {
+		return nil
+	}
+	return fo.file.Sync()
+}
+
+// Rotate
+// @description 立即切分日志文件，不判断文件大小和日期
+func (fo *FileOp) Rotate() error {
+	if !fo.isOpen {
+		if err := fo.ready(); err != nil {
+			return err
+		}
+	}
+
+	if err := fo.archive(); err != nil {
+		return err
+	}
+	return fo.cleanBackups()
+}
 
-		// 获取原文件路径
-		filePreDir := filepath.Dir(fo.path)
-		// 获取原文件名称和扩展名
-		fileNameAndExt := strings.Split(filepath.Base(fo.path), ".")
-		fileName := fileNameAndExt[0]
-		fileExt := fileNameAndExt[1]
-		// 拼接新文件名（fileName-year-month-day-timestamp.fileExt)
-		changeFileName := fmt.Sprintf("%s-%v-%v-%v-%v.%s", fileName, date, int(month), day, timestamp, fileExt)
+// archive
+// @description 切分日志文件，需要压缩时在新的协程中压缩历史日志，不阻塞写入
+func (fo *FileOp) archive() error {
+	changeFilePath, err := fo.rotate()
+	if err != nil {
+		return err
+	}
+
+	// 判断用户是否设置压缩
+	if fo.needCompress {
+		pkgPath := strings.TrimSuffix(changeFilePath, filepath.Ext(fo.path)) + ".zip"
+		fo.setZipping(changeFilePath, pkgPath, true)
+		fo.zips.Add(1)
+		go func() {
+			defer fo.zips.Done()
+			_ = Compress(pkgPath, changeFilePath)
+			// 删除原文件
+			_ = Remove(changeFilePath)
+			fo.setZipping(changeFilePath, pkgPath, false)
+		}()
+	}
+	return nil
+}
+
+// setZipping
+// @description 标记正在压缩的历史日志和压缩包，压缩完成之前清理历史日志时不处理
+func (fo *FileOp) setZipping(path, pkgPath string, zipping bool) {
+	fo.zipMu.Lock()
+	defer fo.zipMu.Unlock()
+	if !zipping {
+		delete(fo.zipping, path)
+		delete(fo.zipping, pkgPath)
+		return
+	}
+	if fo.zipping == nil {
+		fo.zipping = make(map[string]bool)
+	}
+	fo.zipping[path] = true
+	fo.zipping[pkgPath] = true
+}
+
+// isZipping
+// @description 判断历史日志是否正在压缩
+func (fo *FileOp) isZipping(path string) bool {
+	fo.zipMu.Lock()
+	defer fo.zipMu.Unlock()
+	return fo.zipping[path]
+}
+
+// WaitCompress
+// @description 等待后台压缩历史日志完成，用于程序退出前保证历史日志都已经压缩
+func (fo *FileOp) WaitCompress() {
+	fo.zips.Wait()
+}
+
+// rotate
+// @description 将当前文件改名为历史日志 (fileName-year-month-day-timestamp.fileExt)，
+//				并重新创建日志文件，返回历史日志路径，Symlink 布局下当前文件已经使用
+//				历史日志的文件名，只需要创建新文件并修改软链接
+func (fo *FileOp) rotate() (string, error) {
+	_ = fo.Close()
+
+	var changeFilePath string
+	if fo.layout == Layouts.Symlink {
+		changeFilePath = fo.active
+		file, err := fo.createActive()
+		if err != nil {
+			return "", err
+		}
+		fo.file = file
+	} else {
 		// 先改名再压缩是为了防止数据写入时因为压缩速度太慢而造成阻塞
-		changeFilePath, err := ChangeFileName(fo.path, changeFileName)
+		var err error
+		changeFilePath, err = ChangeFileName(fo.path, fo.backupName(fo.curDate))
 		if err != nil {
-			return err
+			return "", err
 		}
-		// 重新初始化 fo.file 继续写
-		_ = fo.ready()
+	}
+	// 重新初始化 fo.file 继续写
+	if err := fo.ready(); err != nil {
+		return "", err
+	}
+	return changeFilePath, nil
+}
 
-		// 判断用户是否设置压缩
-		if fo.needCompress {
-			// 使用同步锁保证压缩过程不会中断
-			wg.Add(1)
-			go func() {
-				wg.Done()
-				pkgName := fmt.Sprintf("%s-%v-%v-%v-%v.%s", fileName, date, int(month), day, timestamp, "zip")
-				pkgPath := filepath.Join(filePreDir, pkgName)
-				_ = Compress(pkgPath, changeFilePath)
-				// 删除原文件
-				_ = Remove(changeFilePath)
-			}()
+// backupName
+// @description 生成历史日志文件名 (fileName-year-month-day-timestamp.fileExt)，日期取自 date，
+//				同一秒内多次切分时时间戳递增，避免覆盖已有的历史日志
+func (fo *FileOp) backupName(date time.Time) string {
+	fileName, fileExt := splitFileName(fo.path)
+	year, month, day := date.Date()
+	timestamp := fo.clock.Now().Unix()
+
+	for {
+		name := fmt.Sprintf("%s-%v-%v-%v-%v%s", fileName, year, int(month), day, timestamp, fileExt)
+		prefix := filepath.Join(filepath.Dir(fo.path), strings.TrimSuffix(name, fileExt))
+		if !IsExists(prefix+fileExt) && !IsExists(prefix+".zip") {
+			return name
 		}
+		timestamp++
 	}
+}
 
-	buf = append(buf, '\n')
-	_, err := fo.file.Write(buf)
+// cleanBackups
+// @description 按照 maxBackups、maxAge 和 maxTotalSize (或者共享的 quota) 清理历史日志，
+//				历史日志的时间取自文件名中的时间戳
+func (fo *FileOp) cleanBackups() error {
+	if fo.quota != nil {
+		defer fo.quota.enforce(fo)
+	}
+	if fo.maxBackups <= 0 && fo.maxAge <= 0 && (fo.maxTotalSize <= 0 || fo.quota != nil) {
+		return nil
+	}
 
-	// 等待压缩完成
-	wg.Wait()
-	return err
+	backups, err := fo.listBackups()
+	if err != nil {
+		return err
+	}
+
+	// 按时间从新到旧排序
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	deadline := fo.clock.Now().AddDate(0, 0, -fo.maxAge)
+	var kept []backupFile
+	total := fo.reserved()
+	for i, b := range backups {
+		if (fo.maxBackups > 0 && i >= fo.maxBackups) || (fo.maxAge > 0 && b.timestamp.Before(deadline)) {
+			_ = Remove(b.path)
+			continue
+		}
+		kept = append(kept, b)
+		total += b.size
+	}
+
+	// 超过总容量时从最早的历史日志开始删除
+	limit := int64(fo.maxTotalSize) * 1024 * 1024
+	for i := len(kept) - 1; i >= 0 && fo.maxTotalSize > 0 && fo.quota == nil && total > limit; i-- {
+		_ = Remove(kept[i].path)
+		total -= kept[i].size
+	}
+	return nil
+}
+
+// backupFile 历史日志文件
+type backupFile struct {
+	path      string
+	timestamp time.Time
+	size      int64
+}
+
+// listBackups
+// @description 列出当前日志的所有历史日志，包括已经压缩的
+func (fo *FileOp) listBackups() ([]backupFile, error) {
+	dir := filepath.Dir(fo.path)
+	fileName, fileExt := splitFileName(fo.path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ts, ok := parseBackupName(entry.Name(), fileName, fileExt)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Symlink 布局下当前日志的文件名和历史日志相同，正在压缩的历史日志压缩完成后再处理
+		if path == fo.active || fo.isZipping(path) {
+			continue
+		}
+		var size int64
+		if info, err := entry.Info(); err == nil {
+			size = info.Size()
+		}
+		backups = append(backups, backupFile{
+			path:      path,
+			timestamp: ts,
+			size:      size,
+		})
+	}
+	return backups, nil
+}
+
+// parseBackupName
+// @description 解析历史日志文件名 (fileName-year-month-day-timestamp.fileExt 或 .zip)，
+//				返回文件名中的时间戳
+func parseBackupName(name, fileName, fileExt string) (time.Time, bool) {
+	if !strings.HasPrefix(name, fileName+"-") {
+		return time.Time{}, false
+	}
+	rest := strings.TrimPrefix(name, fileName+"-")
+	switch {
+	case strings.HasSuffix(rest, ".zip"):
+		rest = strings.TrimSuffix(rest, ".zip")
+	case strings.HasSuffix(rest, fileExt):
+		rest = strings.TrimSuffix(rest, fileExt)
+	default:
+		return time.Time{}, false
+	}
+
+	parts := strings.Split(rest, "-")
+	if len(parts) != 4 {
+		return time.Time{}, false
+	}
+	for _, part := range parts[:3] {
+		if _, err := strconv.Atoi(part); err != nil {
+			return time.Time{}, false
+		}
+	}
+	timestamp, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(timestamp, 0), true
+}
+
+// splitFileName
+// @description 获取文件名称和扩展名 (包含 '.')
+func splitFileName(path string) (string, string) {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext), ext
 }
 
 func (fo *FileOp) Close() error {
@@ -119,14 +514,46 @@ func (fo *FileOp) Close() error {
 	return err
 }
 
+// overDate
+// @description 开启按日期切分时判断当前文件是否已经跨天
+func (fo *FileOp) overDate() bool {
+	if !fo.daily {
+		return false
+	}
+	y1, m1, d1 := fo.curDate.Date()
+	y2, m2, d2 := fo.clock.Now().Date()
+	return y1 != y2 || m1 != m2 || d1 != d2
+}
+
 // overMaxSize
 // @description 判断该 FileOp 指向的文件是否超过最大值
 func (fo *FileOp) overMaxSize() bool {
-	info, _ := fo.file.Stat()
+	return fo.size > int64(fo.maxSize*1024*1024)
+}
 
-	if info.Size() > int64(fo.maxSize*1024*1024) {
-		return true
-	}
+// overMaxLines
+// @description 判断该 FileOp 指向的文件是否达到最大行数
+func (fo *FileOp) overMaxLines() bool {
+	return fo.maxLines > 0 && fo.lines >= int64(fo.maxLines)
+}
 
-	return false
+// countLines
+// @description 统计当前日志的行数，用于程序重启后继续按照行数切分，没有设置 maxLines 时不统计
+func (fo *FileOp) countLines() (int64, error) {
+	if fo.maxLines <= 0 || fo.size == 0 {
+		return 0, nil
+	}
+	var lines int64
+	buf := make([]byte, 32*1024)
+	r := io.NewSectionReader(fo.file, 0, fo.size)
+	for {
+		n, err := r.Read(buf)
+		lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
 }
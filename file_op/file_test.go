@@ -1,8 +1,13 @@
 package file_op
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExists(t *testing.T) {
@@ -78,3 +83,250 @@ func TestBuildBigFile(t *testing.T) {
 		_ = fileOp.Write([]byte("hello world"))
 	}
 }
+
+func TestRotateByDate(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 14, 23, 59, 0, 0, time.Local))
+	fileOp := CreateFileOp(filepath.Join(dir, "app.log"), 10, false).SetClock(clock).SetRotateDaily(true)
+
+	a.Nil(fileOp.Write([]byte("day 1")))
+	clock.Add(2 * time.Minute)
+	a.Nil(fileOp.Write([]byte("day 2")))
+	a.Nil(fileOp.Close())
+
+	backup := filepath.Join(dir, fmt.Sprintf("app-2022-6-14-%d.log", clock.Now().Unix()))
+	content, err := os.ReadFile(backup)
+	a.Nil(err, err)
+	a.Equal("day 1\n", string(content), "历史日志内容错误")
+
+	content, err = os.ReadFile(filepath.Join(dir, "app.log"))
+	a.Nil(err, err)
+	a.Equal("day 2\n", string(content), "当前日志内容错误")
+}
+
+func TestRotateDailyOff(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 14, 23, 59, 0, 0, time.Local))
+	fileOp := CreateFileOp(filepath.Join(dir, "app.log"), 10, false).SetClock(clock)
+
+	a.Nil(fileOp.Write([]byte("day 1")))
+	clock.Add(2 * time.Minute)
+	a.Nil(fileOp.Write([]byte("day 2")))
+	a.Nil(fileOp.Close())
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 0, "没有开启按日期切分时跨天不切分")
+	content, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	a.Nil(err, err)
+	a.Equal("day 1\nday 2\n", string(content))
+}
+
+func TestCompressInBackground(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 14, 12, 0, 0, 0, time.Local))
+	fileOp := CreateFileOp(filepath.Join(dir, "app.log"), 1, true).SetClock(clock).SetMaxBackups(2)
+
+	line := []byte(strings.Repeat("x", 512*1024))
+	for i := 0; i < 12; i++ {
+		a.Nil(fileOp.Write(line))
+		clock.Add(time.Second)
+	}
+	a.Nil(fileOp.Close())
+	fileOp.WaitCompress()
+
+	// 正在压缩的历史日志不参与清理，压缩完成后由之后的切分清理
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.NotEmpty(backups)
+	for _, b := range backups {
+		a.True(strings.HasSuffix(b.path, ".zip"), "历史日志应该被压缩")
+	}
+	a.Nil(fileOp.cleanBackups())
+	backups, err = fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 2, "历史日志清理错误")
+}
+
+func TestResumeExistingFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	clock := NewFakeClock(time.Date(2022, 6, 14, 12, 0, 0, 0, time.Local))
+
+	// 重启前一天写入的日志
+	a.Nil(os.WriteFile(path, []byte("yesterday\n"), 0644))
+	yesterday := time.Date(2022, 6, 13, 23, 0, 0, 0, time.Local)
+	a.Nil(os.Chtimes(path, yesterday, yesterday))
+
+	fileOp := CreateFileOp(path, 1, false).SetClock(clock).SetRotateDaily(true)
+	a.Nil(fileOp.Write([]byte("today")))
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 1, "重启后应该切分前一天的日志")
+	a.True(strings.HasPrefix(filepath.Base(backups[0].path), "app-2022-6-13-"), "历史日志应该使用文件原来的日期")
+	content, err := os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal("today\n", string(content))
+	a.Nil(fileOp.Close())
+
+	// 重启前已经超过 maxSize 的日志
+	a.Nil(os.WriteFile(path, make([]byte, 2*1024*1024), 0644))
+	clock.Add(time.Second)
+	fileOp = CreateFileOp(path, 1, false).SetClock(clock)
+	a.Nil(fileOp.Write([]byte("resumed")))
+	a.Nil(fileOp.Close())
+
+	backups, err = fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 2, "重启后应该切分超过 maxSize 的日志")
+	content, err = os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal("resumed\n", string(content))
+}
+
+func TestMaxLines(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	clock := NewFakeClock(time.Date(2022, 6, 14, 12, 0, 0, 0, time.Local))
+	fileOp := CreateFileOp(path, 10, false).SetClock(clock).SetMaxLines(3)
+
+	for i := 0; i < 7; i++ {
+		a.Nil(fileOp.Write([]byte(fmt.Sprintf("line %d", i))))
+	}
+	a.Nil(fileOp.Close())
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 2, "每 3 行切分一次")
+	for _, b := range backups {
+		content, err := os.ReadFile(b.path)
+		a.Nil(err, err)
+		a.Equal(3, strings.Count(string(content), "\n"), "历史日志应该正好 3 行")
+	}
+
+	// 重启后统计已有的行数，当前日志还可以写入 2 行
+	fileOp = CreateFileOp(path, 10, false).SetClock(clock).SetMaxLines(3)
+	a.Nil(fileOp.Write([]byte("line 7")))
+	a.Nil(fileOp.Write([]byte("line 8")))
+	backups, err = fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 2, "没有达到最大行数时不切分")
+
+	a.Nil(fileOp.Write([]byte("line 9")))
+	a.Nil(fileOp.Close())
+	backups, err = fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 3, "重启后应该继续按照行数切分")
+	content, err := os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal("line 9\n", string(content))
+}
+
+func TestCleanBackups(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.Local))
+	fileOp := CreateFileOp(filepath.Join(dir, "app.log"), 10, false).
+		SetClock(clock).
+		SetRotateDaily(true).
+		SetMaxBackups(3).
+		SetMaxAge(1)
+
+	// 每天写一条日志，产生 4 个历史日志
+	for i := 0; i < 5; i++ {
+		a.Nil(fileOp.Write([]byte("hello world")))
+		clock.Add(24 * time.Hour)
+	}
+	a.Nil(fileOp.Close())
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	// maxAge 为 1 天，只保留最近一天内产生的历史日志
+	a.Len(backups, 2, "历史日志清理错误")
+
+	fileOp.SetMaxAge(0).SetMaxBackups(1)
+	a.Nil(fileOp.cleanBackups())
+	backups, err = fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 1, "历史日志清理错误")
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.Local))
+	fileOp := CreateFileOp(filepath.Join(dir, "app.log"), 1, false).
+		SetClock(clock).
+		SetMaxTotalSize(3)
+
+	// 每个文件写入 2 行 512KB 的日志后切分，总共产生 5 个历史日志，
+	// 当前日志按照 1MB 计算，只能再保留 1 个 1MB 的历史日志
+	line := []byte(strings.Repeat("x", 512*1024))
+	for i := 0; i < 12; i++ {
+		a.Nil(fileOp.Write(line))
+		clock.Add(time.Second)
+	}
+	a.Nil(fileOp.Close())
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 1, "超过总容量时应该删除最早的历史日志")
+
+	var total int64
+	entries, err := os.ReadDir(dir)
+	a.Nil(err, err)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		a.Nil(err, err)
+		total += info.Size()
+	}
+	a.LessOrEqual(total, int64(3*1024*1024), "日志总容量不能超过 maxTotalSize")
+
+	// 保留的是最新的历史日志
+	for _, b := range backups {
+		a.True(b.timestamp.After(time.Date(2022, 6, 1, 12, 0, 5, 0, time.Local)), "应该保留最新的历史日志")
+	}
+}
+
+func TestReopenMovedFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	clock := NewFakeClock(time.Date(2022, 6, 14, 12, 0, 0, 0, time.Local))
+	fileOp := CreateFileOp(path, 10, false).SetClock(clock).SetCheckInterval(time.Second)
+
+	a.Nil(fileOp.Write([]byte("first")))
+	// 模拟 logrotate 移动日志文件，检查间隔内继续写入原来的文件
+	a.Nil(os.Rename(path, path+".1"))
+	a.Nil(fileOp.Write([]byte("second")))
+	clock.Add(2 * time.Second)
+	a.Nil(fileOp.Write([]byte("third")))
+
+	// 日志文件被删除
+	clock.Add(2 * time.Second)
+	a.Nil(os.Remove(path))
+	a.Nil(fileOp.Write([]byte("fourth")))
+	a.Nil(fileOp.Close())
+
+	content, err := os.ReadFile(path + ".1")
+	a.Nil(err, err)
+	a.Equal("first\nsecond\n", string(content), "检查间隔内应该写入原来的文件")
+
+	content, err = os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal("fourth\n", string(content), "文件被删除后应该重新创建")
+}
@@ -0,0 +1,92 @@
+package file_op
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Layout 日志文件布局
+type Layout byte
+
+// Layouts 日志文件布局选项
+var Layouts = struct {
+	Rename   Layout // 当前日志使用固定的文件名，切分时改名为历史日志
+	Symlink  Layout // 当前日志使用带时间戳的文件名，固定的文件名是指向当前日志的软链接，切分时创建新文件并修改软链接
+	Truncate Layout // 当前日志使用固定的文件名，允许外部工具复制后清空文件 (logrotate copytruncate)
+	Default  Layout
+}{0, 1, 2, 0}
+
+// SetLayout
+// @description 设置日志文件布局
+func (fo *FileOp) SetLayout(layout Layout) *FileOp {
+	fo.layout = layout
+	return fo
+}
+
+// openActive
+// @description Symlink 布局下打开当前日志，软链接指向的文件存在时继续写入，否则创建新文件，
+//				固定的文件名是普通文件时 (之前使用 Rename 布局) 先改名为历史日志
+func (fo *FileOp) openActive() (*os.File, error) {
+	if target, err := os.Readlink(fo.path); err == nil {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(fo.path), target)
+		}
+		if IsExists(target) {
+			file, err := MustOpenFile(target)
+			if err != nil {
+				return nil, err
+			}
+			fo.active = target
+			return file, nil
+		}
+	} else if IsExists(fo.path) {
+		if _, err := ChangeFileName(fo.path, fo.backupName(fo.clock.Now())); err != nil {
+			return nil, err
+		}
+	}
+	return fo.createActive()
+}
+
+// createActive
+// @description Symlink 布局下创建带时间戳的当前日志，并将软链接指向它，
+//				软链接先创建为临时文件再改名，保证固定的文件名一直可用
+func (fo *FileOp) createActive() (*os.File, error) {
+	if err := mkdirForPath(fo.path); err != nil {
+		return nil, err
+	}
+	active := filepath.Join(filepath.Dir(fo.path), fo.backupName(fo.clock.Now()))
+	file, err := CreateFile(active)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := fo.path + ".tmp"
+	_ = os.Remove(tmp)
+	if err = os.Symlink(filepath.Base(active), tmp); err == nil {
+		err = os.Rename(tmp, fo.path)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	fo.active = active
+	return file, nil
+}
+
+// truncated
+// @description Truncate 布局下判断文件是否被外部工具清空，清空后重新计算文件大小和行数
+func (fo *FileOp) truncated() (bool, error) {
+	if fo.layout != Layouts.Truncate {
+		return false, nil
+	}
+	info, err := fo.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() >= fo.size {
+		return false, nil
+	}
+	fo.size = info.Size()
+	fo.lines, err = fo.countLines()
+	return true, err
+}
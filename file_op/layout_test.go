@@ -0,0 +1,98 @@
+package file_op
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSymlinkLayout(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	clock := NewFakeClock(time.Date(2022, 6, 14, 12, 0, 0, 0, time.Local))
+	first := fmt.Sprintf("app-2022-6-14-%d.log", clock.Now().Unix())
+
+	fileOp := CreateFileOp(path, 10, false).SetClock(clock).SetLayout(Layouts.Symlink).SetRotateDaily(true)
+	a.Nil(fileOp.Write([]byte("day 1")))
+	target, err := os.Readlink(path)
+	a.Nil(err, err)
+	a.Equal(first, target, "软链接应该指向带时间戳的当前日志")
+
+	clock.Add(24 * time.Hour)
+	second := fmt.Sprintf("app-2022-6-15-%d.log", clock.Now().Unix())
+	a.Nil(fileOp.Write([]byte("day 2")))
+	a.Nil(fileOp.Close())
+
+	target, err = os.Readlink(path)
+	a.Nil(err, err)
+	a.Equal(second, target, "切分后软链接应该指向新的日志")
+
+	content, err := os.ReadFile(filepath.Join(dir, first))
+	a.Nil(err, err)
+	a.Equal("day 1\n", string(content))
+	content, err = os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal("day 2\n", string(content))
+
+	// 重新打开时继续写入软链接指向的文件，当前日志不算历史日志
+	fileOp = CreateFileOp(path, 10, false).SetClock(clock).SetLayout(Layouts.Symlink)
+	a.Nil(fileOp.Write([]byte("restart")))
+	a.Nil(fileOp.Close())
+	content, err = os.ReadFile(filepath.Join(dir, second))
+	a.Nil(err, err)
+	a.Equal("day 2\nrestart\n", string(content))
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 1)
+	a.Equal(filepath.Join(dir, first), backups[0].path)
+}
+
+func TestSymlinkLayoutFromRegularFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	a.Nil(os.WriteFile(path, []byte("old\n"), 0666))
+
+	fileOp := CreateFileOp(path, 10, false).SetLayout(Layouts.Symlink)
+	a.Nil(fileOp.Write([]byte("new")))
+	a.Nil(fileOp.Close())
+
+	info, err := os.Lstat(path)
+	a.Nil(err, err)
+	a.NotZero(info.Mode()&os.ModeSymlink, "普通文件应该替换为软链接")
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 1, "原来的日志应该保留为历史日志")
+	content, err := os.ReadFile(backups[0].path)
+	a.Nil(err, err)
+	a.Equal("old\n", string(content))
+}
+
+func TestTruncateLayout(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	fileOp := CreateFileOp(path, 10, false).SetLayout(Layouts.Truncate)
+
+	a.Nil(fileOp.Write([]byte("before copytruncate")))
+	a.Equal(int64(20), fileOp.size)
+
+	// 模拟 logrotate copytruncate
+	a.Nil(os.Truncate(path, 0))
+	a.Nil(fileOp.Write([]byte("after")))
+	a.Equal(int64(6), fileOp.size, "文件被清空后应该重新计算大小")
+	a.Nil(fileOp.Close())
+
+	content, err := os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal("after\n", string(content), "清空后应该从文件开头写入")
+}
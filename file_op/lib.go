@@ -35,6 +35,16 @@ func Mkdir(path string) error {
 	return nil
 }
 
+// mkdirForPath
+// @description 创建日志文件所在的目录
+func mkdirForPath(path string) error {
+	dir := filepath.Dir(path)
+	if IsExists(dir) {
+		return nil
+	}
+	return Mkdir(dir)
+}
+
 // CreateFile
 // @description 创建文件，先检查文件是否存在，存在就报错，不存在就创建
 func CreateFile(path string) (*os.File, error) {
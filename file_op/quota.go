@@ -0,0 +1,92 @@
+package file_op
+
+import (
+	"sort"
+	"sync"
+)
+
+// Quota
+// @description 多个 FileOp 共享的总容量限制，例如主日志和路由规则写入的日志，任意一个日志切分时
+//				统计所有日志和历史日志的总大小，超过后从所有历史日志中最早的开始删除
+type Quota struct {
+	mu    sync.Mutex
+	limit int64              // 总容量，单位: 字节
+	files map[string]*FileOp // 使用该限制的日志，按照路径保存，同一路径重新创建的 FileOp 替换之前的
+}
+
+// NewQuota
+// @param maxTotalSize 总容量，以 MB 为单位
+// @description 创建共享的总容量限制
+func NewQuota(maxTotalSize int) *Quota {
+	return &Quota{
+		limit: int64(maxTotalSize) * 1024 * 1024,
+		files: make(map[string]*FileOp),
+	}
+}
+
+// SetQuota
+// @description 设置和其他日志共享的总容量限制，设置后 maxTotalSize 不再生效
+func (fo *FileOp) SetQuota(quota *Quota) *FileOp {
+	fo.quota = quota
+	quota.mu.Lock()
+	quota.files[fo.path] = fo
+	quota.mu.Unlock()
+	return fo
+}
+
+// enforce
+// @description 统计所有日志和历史日志的总大小，超过总容量时从最早的历史日志开始删除，
+//				已经关闭的日志 (例如按照字段值生成的文件) 的历史日志同样计算在内
+func (q *Quota) enforce(fo *FileOp) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// 使用路径模板时路径会变化
+	q.files[fo.path] = fo
+
+	var total int64
+	var backups []backupFile
+	seen := make(map[*FileOp]bool)
+	listed := make(map[string]bool)
+	for _, f := range q.files {
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		total += f.reserved()
+
+		list, err := f.listBackups()
+		if err != nil {
+			continue
+		}
+		for _, b := range list {
+			if !listed[b.path] {
+				listed[b.path] = true
+				backups = append(backups, b)
+				total += b.size
+			}
+		}
+	}
+
+	// 按时间从旧到新删除
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.Before(backups[j].timestamp)
+	})
+	for i := 0; i < len(backups) && total > q.limit; i++ {
+		_ = Remove(backups[i].path)
+		total -= backups[i].size
+	}
+}
+
+// reserved
+// @description 当前日志占用的容量，打开的日志按照 maxSize 计算，保证切分之前继续写入也不会超过总容量
+func (fo *FileOp) reserved() int64 {
+	if !fo.isOpen {
+		return fo.size
+	}
+	size := int64(fo.maxSize) * 1024 * 1024
+	if fo.size > size {
+		size = fo.size
+	}
+	return size
+}
@@ -0,0 +1,50 @@
+package file_op
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 1, 12, 0, 0, 0, time.Local))
+	quota := NewQuota(5)
+	app := CreateFileOp(filepath.Join(dir, "app.log"), 1, false).SetClock(clock).SetQuota(quota)
+	audit := CreateFileOp(filepath.Join(dir, "audit.log"), 1, false).SetClock(clock).SetQuota(quota)
+
+	// 两个日志各自都在 5MB 以内，合计超过总容量
+	line := []byte(strings.Repeat("x", 512*1024))
+	for i := 0; i < 8; i++ {
+		a.Nil(app.Write(line))
+		clock.Add(time.Second)
+		a.Nil(audit.Write(line))
+		clock.Add(time.Second)
+	}
+	a.Nil(app.Close())
+	a.Nil(audit.Close())
+
+	var total int64
+	entries, err := os.ReadDir(dir)
+	a.Nil(err, err)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		a.Nil(err, err)
+		total += info.Size()
+	}
+	a.LessOrEqual(total, int64(5*1024*1024), "所有日志的总容量不能超过共享的总容量")
+
+	// 两个日志的当前日志各占 1MB，历史日志略大于 1MB，只能保留最新的 2 个历史日志
+	appBackups, err := app.listBackups()
+	a.Nil(err, err)
+	auditBackups, err := audit.listBackups()
+	a.Nil(err, err)
+	a.Len(append(appBackups, auditBackups...), 2, "应该从所有日志中最早的历史日志开始删除")
+	a.Len(auditBackups, 1, "最新的历史日志应该保留")
+	a.Len(appBackups, 1, "最新的历史日志应该保留")
+}
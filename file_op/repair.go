@@ -0,0 +1,141 @@
+package file_op
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// RepairFunc
+// @description 修复日志文件末尾不完整的行之后调用，path 为日志文件，corruptPath 为保存不完整内容的文件，
+//				n 为移动的字节数，无法写入 .corrupt 文件或者最后一行超过 1MB 时只在末尾补上换行，corruptPath 为空
+type RepairFunc func(path, corruptPath string, n int)
+
+// maxRepairLine 修复时最多向前读取的字节数，最后一行超过该长度时不移动，只补上换行
+const maxRepairLine = 1024 * 1024
+
+// SetRepair
+// @description 设置打开已有的日志文件时修复末尾不完整的行，例如进程在写入时被杀死，
+//				没有换行结尾或者 valid 返回 false 的最后一行会被移动到 "文件名.corrupt"，
+//				避免之后的日志拼接在不完整的行后面，valid 为 nil 时只检查换行
+func (fo *FileOp) SetRepair(valid func(line []byte) bool, onRepair RepairFunc) *FileOp {
+	fo.repair = true
+	fo.newline = false
+	fo.valid = valid
+	fo.onRepair = onRepair
+	return fo
+}
+
+// SetRepairNewline
+// @description 设置打开已有的日志文件时只在没有换行结尾的最后一行末尾补上换行，不移动不完整的内容
+func (fo *FileOp) SetRepairNewline(onRepair RepairFunc) *FileOp {
+	fo.repair = true
+	fo.newline = true
+	fo.valid = nil
+	fo.onRepair = onRepair
+	return fo
+}
+
+// repairTail
+// @description 检查日志文件的最后一行，不完整时追加到 .corrupt 文件中并从日志文件中截断
+func (fo *FileOp) repairTail() error {
+	if !fo.repair || fo.size == 0 {
+		return nil
+	}
+	limit := int64(maxRepairLine)
+	if fo.newline {
+		limit = 1
+	}
+	start, line, err := lastLine(fo.file, fo.size, limit)
+	if err != nil {
+		return err
+	}
+	if start < 0 || fo.newline {
+		// 只检查换行或者最后一行过长
+		return fo.terminate(line)
+	}
+	complete := bytes.HasSuffix(line, []byte{'\n'})
+	if complete && (fo.valid == nil || fo.valid(line[:len(line)-1])) {
+		return nil
+	}
+
+	corruptPath := fo.file.Name() + ".corrupt"
+	if err = appendFile(corruptPath, line, complete); err != nil {
+		// 无法保存时只补上换行，保证之后的日志从新的一行开始
+		return fo.terminate(line)
+	}
+
+	if err = fo.file.Truncate(start); err != nil {
+		return err
+	}
+	n := int(fo.size - start)
+	fo.size = start
+	if fo.onRepair != nil {
+		fo.onRepair(fo.file.Name(), corruptPath, n)
+	}
+	return nil
+}
+
+// terminate
+// @description 最后一行没有换行结尾时补上换行，保证之后的日志从新的一行开始
+func (fo *FileOp) terminate(line []byte) error {
+	if bytes.HasSuffix(line, []byte{'\n'}) {
+		return nil
+	}
+	n, err := fo.file.Write([]byte{'\n'})
+	fo.size += int64(n)
+	if err == nil && fo.onRepair != nil {
+		fo.onRepair(fo.file.Name(), "", 0)
+	}
+	return err
+}
+
+// appendFile
+// @description 追加内容到文件末尾，complete 为 false 时补上换行
+func appendFile(path string, line []byte, complete bool) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	if !complete {
+		line = append(line, '\n')
+	}
+	_, err = file.Write(line)
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// lastLine
+// @description 从文件末尾向前读取最后一行 (包含结尾的换行)，返回最后一行的起始位置，
+//				最多读取 limit 字节，超过后仍然没有找到行首时返回 -1 和读取到的内容
+func lastLine(file *os.File, size, limit int64) (int64, []byte, error) {
+	const chunk = 4096
+	var tail []byte
+	end := size
+	floor := size - limit
+	if floor < 0 {
+		floor = 0
+	}
+	for end > floor {
+		start := end - chunk
+		if start < floor {
+			start = floor
+		}
+		buf := make([]byte, end-start)
+		if _, err := file.ReadAt(buf, start); err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		tail = append(buf, tail...)
+		// 跳过最后一个字符，它可能是最后一行结尾的换行
+		if i := bytes.LastIndexByte(tail[:len(tail)-1], '\n'); i >= 0 {
+			return size - int64(len(tail)) + int64(i) + 1, tail[i+1:], nil
+		}
+		end = start
+	}
+	if floor > 0 {
+		return -1, tail, nil
+	}
+	return 0, tail, nil
+}
@@ -0,0 +1,122 @@
+package file_op
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepairTail(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	// 进程在写入第二行时被杀死
+	a.Nil(os.WriteFile(path, []byte(`{"message":"ok"}`+"\n"+`{"message":"par`), 0644))
+
+	var repaired []int
+	fileOp := CreateFileOp(path, 10, false).SetRepair(json.Valid, func(p, corruptPath string, n int) {
+		a.Equal(path, p)
+		a.Equal(path+".corrupt", corruptPath)
+		repaired = append(repaired, n)
+	})
+	a.Nil(fileOp.Write([]byte(`{"message":"next"}`)))
+	a.Nil(fileOp.Close())
+
+	a.Equal([]int{len(`{"message":"par`)}, repaired, "修复后应该通知调用方")
+	content, err := os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal(`{"message":"ok"}`+"\n"+`{"message":"next"}`+"\n", string(content), "不完整的行应该从日志中移除")
+	content, err = os.ReadFile(path + ".corrupt")
+	a.Nil(err, err)
+	a.Equal(`{"message":"par`+"\n", string(content))
+
+	// 以换行结尾但不是合法 Json 的行
+	a.Nil(os.WriteFile(path, []byte(`{"message":"ok"}`+"\n"+`{"mess{"message":"x"}`+"\n"), 0644))
+	a.Nil(fileOp.Reopen())
+	a.Nil(fileOp.Close())
+	content, err = os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal(`{"message":"ok"}`+"\n", string(content))
+	a.Len(repaired, 2)
+
+	// 完整的日志不需要修复
+	a.Nil(fileOp.Reopen())
+	a.Nil(fileOp.Close())
+	a.Len(repaired, 2, "完整的日志不需要修复")
+}
+
+func TestRepairTailLongLine(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	// 不完整的行超过一次读取的长度
+	first := strings.Repeat("a", 5000) + "\n"
+	partial := strings.Repeat("b", 10000)
+	a.Nil(os.WriteFile(path, []byte(first+partial), 0644))
+
+	fileOp := CreateFileOp(path, 10, false).SetRepair(nil, nil)
+	a.Nil(fileOp.Write([]byte("next")))
+	a.Nil(fileOp.Close())
+
+	content, err := os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal(first+"next\n", string(content))
+	content, err = os.ReadFile(path + ".corrupt")
+	a.Nil(err, err)
+	a.Equal(partial+"\n", string(content))
+}
+
+func TestRepairTailOverLimit(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	// 不完整的行超过向前读取的上限，只补上换行
+	partial := strings.Repeat("b", maxRepairLine+maxRepairLine/2)
+	a.Nil(os.WriteFile(path, []byte("first\n"+partial), 0644))
+
+	var repaired []string
+	fileOp := CreateFileOp(path, 10, false).SetRepair(json.Valid, func(p, corruptPath string, n int) {
+		repaired = append(repaired, corruptPath)
+	})
+	a.Nil(fileOp.Write([]byte("next")))
+	a.Nil(fileOp.Close())
+
+	content, err := os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal("first\n"+partial+"\nnext\n", string(content), "过长的行应该保留在日志中")
+	a.NoFileExists(path+".corrupt", "过长的行不应该移动")
+	a.Equal([]string{""}, repaired)
+}
+
+func TestRepairNewline(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	a.Nil(os.WriteFile(path, []byte(`{"message":"ok"}`+"\n"+`{"message":"par`), 0644))
+
+	repaired := 0
+	fileOp := CreateFileOp(path, 10, false).SetRepairNewline(func(p, corruptPath string, n int) {
+		a.Empty(corruptPath)
+		repaired++
+	})
+	a.Nil(fileOp.Write([]byte(`{"message":"next"}`)))
+	a.Nil(fileOp.Close())
+
+	content, err := os.ReadFile(path)
+	a.Nil(err, err)
+	a.Equal(`{"message":"ok"}`+"\n"+`{"message":"par`+"\n"+`{"message":"next"}`+"\n", string(content), "只补上换行")
+	a.NoFileExists(path + ".corrupt")
+	a.Equal(1, repaired)
+
+	// 以换行结尾的行不需要修复
+	a.Nil(fileOp.Reopen())
+	a.Nil(fileOp.Close())
+	a.Equal(1, repaired)
+}
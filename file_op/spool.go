@@ -0,0 +1,367 @@
+package file_op
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// spoolHeaderSize 每条记录前 4 个字节保存记录长度
+const spoolHeaderSize = 4
+
+// SpoolPos
+// @description 预写队列中的位置，Seg 为段编号，Off 为段中的偏移，零值表示不在预写队列中
+type SpoolPos struct {
+	Seg int64
+	Off int64
+}
+
+// IsZero
+// @description 判断是否为零值
+func (p SpoolPos) IsZero() bool {
+	return p == SpoolPos{}
+}
+
+// Before
+// @description 判断是否在 other 之前
+func (p SpoolPos) Before(other SpoolPos) bool {
+	return p.Seg < other.Seg || (p.Seg == other.Seg && p.Off < other.Off)
+}
+
+// Spool
+// @description 基于磁盘的预写队列，记录追加到段文件 (segment) 后才算写入成功，
+//				消费者读取记录并处理完成后调用 Commit 或 CommitTo 记录已确认的位置，重启后
+//				从上次确认的位置重新读取，保证至少一次 (at-least-once) 投递
+type Spool struct {
+	mu          sync.Mutex
+	dir         string
+	segmentSize int64 // 单个段文件最大字节数
+	retention   int   // 已确认的段文件最多保留个数
+	sync        bool  // 每次追加后是否调用 fsync
+
+	writeSeg  int64    // 正在写入的段
+	writeFile *os.File // 正在写入的段文件
+	writeSize int64    // 正在写入的段文件大小
+
+	readSeg  int64    // 下一条记录所在的段
+	readOff  int64    // 下一条记录在段中的位置
+	readFile *os.File // 正在读取的段文件
+
+	notify chan struct{}
+}
+
+// OpenSpool
+// @param dir 段文件保存目录
+// @param segmentSize 单个段文件最大字节数
+// @param retention 已确认的段文件最多保留个数，0 表示确认后立即删除
+// @description 打开预写队列，目录中已经存在段文件时从上次确认的位置继续读取
+func OpenSpool(dir string, segmentSize int64, retention int) (*Spool, error) {
+	if err := Mkdir(dir); err != nil {
+		return nil, err
+	}
+
+	sp := &Spool{
+		dir:         dir,
+		segmentSize: segmentSize,
+		retention:   retention,
+		notify:      make(chan struct{}, 1),
+	}
+
+	segments, err := sp.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	// 读取上次确认的位置
+	sp.readSeg, sp.readOff, err = sp.loadCommit()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) != 0 && sp.readSeg < segments[0] {
+		sp.readSeg, sp.readOff = segments[0], 0
+	}
+
+	// 继续写最后一个段文件
+	sp.writeSeg = sp.readSeg
+	if len(segments) != 0 && segments[len(segments)-1] > sp.writeSeg {
+		sp.writeSeg = segments[len(segments)-1]
+	}
+	if err = sp.openWriteSegment(); err != nil {
+		return nil, err
+	}
+
+	// 存在未确认的记录时通知消费者
+	if sp.readSeg < sp.writeSeg || sp.readOff < sp.writeSize {
+		sp.notify <- struct{}{}
+	}
+	return sp, nil
+}
+
+// SetSync
+// @description 设置每次追加后是否调用 fsync
+func (sp *Spool) SetSync(sync bool) *Spool {
+	sp.sync = sync
+	return sp
+}
+
+// Notify
+// @description 有新记录写入时会收到通知
+func (sp *Spool) Notify() <-chan struct{} {
+	return sp.notify
+}
+
+// Append
+// @description 追加一条记录，返回时记录已经写入段文件
+func (sp *Spool) Append(data []byte) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.writeFile == nil {
+		return errors.New("spool is closed")
+	}
+
+	// 当前段文件超过最大值时切换到新的段
+	if sp.writeSize > 0 && sp.writeSize+int64(len(data))+spoolHeaderSize > sp.segmentSize {
+		_ = sp.writeFile.Close()
+		sp.writeSeg++
+		if err := sp.openWriteSegment(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, spoolHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	copy(record[spoolHeaderSize:], data)
+	if _, err := sp.writeFile.Write(record); err != nil {
+		return err
+	}
+	sp.writeSize += int64(len(record))
+	if sp.sync {
+		if err := sp.writeFile.Sync(); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case sp.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Next
+// @description 读取下一条未处理的记录，没有记录时 ok 返回 false
+func (sp *Spool) Next() (data []byte, ok bool, err error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	for {
+		if sp.readFile == nil {
+			if sp.readFile, err = os.Open(sp.segmentPath(sp.readSeg)); err != nil {
+				return nil, false, err
+			}
+		}
+
+		// 当前段已经读完时切换到下一个段
+		end := sp.writeSize
+		if sp.readSeg < sp.writeSeg {
+			info, err := sp.readFile.Stat()
+			if err != nil {
+				return nil, false, err
+			}
+			end = info.Size()
+		}
+		if sp.readOff+spoolHeaderSize > end {
+			if sp.readSeg >= sp.writeSeg {
+				return nil, false, nil
+			}
+			_ = sp.readFile.Close()
+			sp.readFile = nil
+			sp.readSeg++
+			sp.readOff = 0
+			continue
+		}
+
+		header := make([]byte, spoolHeaderSize)
+		if _, err = sp.readFile.ReadAt(header, sp.readOff); err != nil {
+			return nil, false, err
+		}
+		data = make([]byte, binary.BigEndian.Uint32(header))
+		if _, err = sp.readFile.ReadAt(data, sp.readOff+spoolHeaderSize); err != nil {
+			return nil, false, err
+		}
+		sp.readOff += spoolHeaderSize + int64(len(data))
+		return data, true, nil
+	}
+}
+
+// Pos
+// @description 获取下一条记录的位置，在 Next 之后调用时为刚刚读取的记录的结束位置
+func (sp *Spool) Pos() SpoolPos {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return SpoolPos{Seg: sp.readSeg, Off: sp.readOff}
+}
+
+// Commit
+// @description 确认已经读取的记录，并清理已经确认的段文件
+func (sp *Spool) Commit() error {
+	return sp.CommitTo(sp.Pos())
+}
+
+// CommitTo
+// @description 确认 pos 之前的记录，并清理已经确认的段文件，pos 不能超过已经读取的位置，
+//				用于记录被异步处理时只确认已经处理完成的部分
+func (sp *Spool) CommitTo(pos SpoolPos) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if read := (SpoolPos{Seg: sp.readSeg, Off: sp.readOff}); read.Before(pos) {
+		pos = read
+	}
+
+	// 先写临时文件再改名，保证确认位置不会因为崩溃而损坏
+	tmp := filepath.Join(sp.dir, "commit.tmp")
+	content := fmt.Sprintf("%d %d", pos.Seg, pos.Off)
+	if err := os.WriteFile(tmp, []byte(content), 0666); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(sp.dir, "commit")); err != nil {
+		return err
+	}
+
+	segments, err := sp.segments()
+	if err != nil {
+		return err
+	}
+	var committed []int64
+	for _, seg := range segments {
+		if seg < pos.Seg {
+			committed = append(committed, seg)
+		}
+	}
+	for i := 0; i < len(committed)-sp.retention; i++ {
+		_ = Remove(sp.segmentPath(committed[i]))
+	}
+	return nil
+}
+
+// Close
+// @description 关闭预写队列，未确认的记录会在下次打开时重新读取
+func (sp *Spool) Close() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.readFile != nil {
+		_ = sp.readFile.Close()
+		sp.readFile = nil
+	}
+	if sp.writeFile == nil {
+		return nil
+	}
+	err := sp.writeFile.Close()
+	sp.writeFile = nil
+	return err
+}
+
+// openWriteSegment
+// @description 打开正在写入的段文件，文件末尾不完整的记录 (写入时崩溃) 会被截断
+func (sp *Spool) openWriteSegment() error {
+	path := sp.segmentPath(sp.writeSeg)
+
+	var err error
+	if !IsExists(path) {
+		sp.writeFile, err = CreateFile(path)
+		sp.writeSize = 0
+		return err
+	}
+
+	if sp.writeFile, err = MustOpenFile(path); err != nil {
+		return err
+	}
+	size, err := validSize(sp.writeFile)
+	if err != nil {
+		return err
+	}
+	if err = sp.writeFile.Truncate(size); err != nil {
+		return err
+	}
+	sp.writeSize = size
+	return nil
+}
+
+// validSize
+// @description 计算段文件中完整记录的总长度
+func validSize(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	var off int64
+	header := make([]byte, spoolHeaderSize)
+	for off+spoolHeaderSize <= info.Size() {
+		if _, err = f.ReadAt(header, off); err != nil && err != io.EOF {
+			return 0, err
+		}
+		next := off + spoolHeaderSize + int64(binary.BigEndian.Uint32(header))
+		if next > info.Size() {
+			break
+		}
+		off = next
+	}
+	return off, nil
+}
+
+// loadCommit
+// @description 读取上次确认的位置
+func (sp *Spool) loadCommit() (int64, int64, error) {
+	path := filepath.Join(sp.dir, "commit")
+	if !IsExists(path) {
+		return 0, 0, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var seg, off int64
+	if _, err = fmt.Sscanf(string(content), "%d %d", &seg, &off); err != nil {
+		return 0, 0, fmt.Errorf("invalid spool commit file: %w", err)
+	}
+	return seg, off, nil
+}
+
+// segments
+// @description 按顺序列出所有段文件编号
+func (sp *Spool) segments() ([]int64, error) {
+	entries, err := os.ReadDir(sp.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".seg") {
+			continue
+		}
+		seg, err := strconv.ParseInt(strings.TrimSuffix(name, ".seg"), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (sp *Spool) segmentPath(seg int64) string {
+	return filepath.Join(sp.dir, fmt.Sprintf("%020d.seg", seg))
+}
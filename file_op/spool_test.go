@@ -0,0 +1,133 @@
+package file_op
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readAll(t *testing.T, sp *Spool) []string {
+	var records []string
+	for {
+		data, ok, err := sp.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return records
+		}
+		records = append(records, string(data))
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	sp, err := OpenSpool(dir, 1024, 0)
+	a.Nil(err, err)
+
+	for i := 0; i < 3; i++ {
+		a.Nil(sp.Append([]byte(fmt.Sprintf("record-%d", i))))
+	}
+	a.Equal([]string{"record-0", "record-1", "record-2"}, readAll(t, sp))
+	a.Nil(sp.Commit())
+
+	// 写入后没有确认就崩溃
+	a.Nil(sp.Append([]byte("record-3")))
+	a.Equal([]string{"record-3"}, readAll(t, sp))
+	a.Nil(sp.Close())
+
+	sp, err = OpenSpool(dir, 1024, 0)
+	a.Nil(err, err)
+	select {
+	case <-sp.Notify():
+	default:
+		t.Fatal("存在未确认的记录时应该通知消费者")
+	}
+	a.Equal([]string{"record-3"}, readAll(t, sp), "应该重新读取未确认的记录")
+	a.Nil(sp.Close())
+}
+
+func TestSpoolSegments(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	// 每个段最多保存 2 条记录 (4 字节头 + 8 字节数据)
+	sp, err := OpenSpool(dir, 24, 1)
+	a.Nil(err, err)
+
+	for i := 0; i < 5; i++ {
+		a.Nil(sp.Append([]byte(fmt.Sprintf("record-%d", i))))
+	}
+	segments, err := sp.segments()
+	a.Nil(err, err)
+	a.Len(segments, 3, "段文件切分错误")
+
+	a.Len(readAll(t, sp), 5)
+	a.Nil(sp.Commit())
+	segments, err = sp.segments()
+	a.Nil(err, err)
+	a.Len(segments, 2, "已确认的段文件只保留 1 个")
+	a.Nil(sp.Close())
+}
+
+func TestSpoolTornTail(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	sp, err := OpenSpool(dir, 1024, 0)
+	a.Nil(err, err)
+	a.Nil(sp.Append([]byte("complete")))
+	a.Nil(sp.Close())
+
+	// 模拟写入一半时崩溃
+	f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%020d.seg", 0)), os.O_APPEND|os.O_WRONLY, 0666)
+	a.Nil(err, err)
+	_, _ = f.Write([]byte{0, 0, 0, 9, 'p', 'a'})
+	_ = f.Close()
+
+	sp, err = OpenSpool(dir, 1024, 0)
+	a.Nil(err, err)
+	a.Nil(sp.Append([]byte("after")))
+	a.Equal([]string{"complete", "after"}, readAll(t, sp), "不完整的记录应该被截断")
+	a.Nil(sp.Close())
+}
+
+func TestSpoolCommitTo(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	sp, err := OpenSpool(dir, 1024, 0)
+	a.Nil(err, err)
+	a.True(sp.Pos().IsZero())
+
+	var positions []SpoolPos
+	for i := 0; i < 3; i++ {
+		a.Nil(sp.Append([]byte(fmt.Sprintf("record-%d", i))))
+		_, ok, err := sp.Next()
+		a.True(ok)
+		a.Nil(err, err)
+		positions = append(positions, sp.Pos())
+	}
+	a.True(positions[0].Before(positions[1]))
+	a.False(positions[1].Before(positions[0]))
+
+	// 只确认第一条记录，之后的记录在重启后重新读取
+	a.Nil(sp.CommitTo(positions[0]))
+	a.Nil(sp.Close())
+	sp, err = OpenSpool(dir, 1024, 0)
+	a.Nil(err, err)
+	a.Equal([]string{"record-1", "record-2"}, readAll(t, sp), "应该重新读取未确认的记录")
+
+	// 确认的位置不能超过已经读取的位置
+	a.Nil(sp.Append([]byte("record-3")))
+	a.Nil(sp.CommitTo(SpoolPos{Seg: 1 << 20}))
+	a.Nil(sp.Close())
+	sp, err = OpenSpool(dir, 1024, 0)
+	a.Nil(err, err)
+	a.Equal([]string{"record-3"}, readAll(t, sp))
+	a.Nil(sp.Close())
+}
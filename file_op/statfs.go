@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package file_op
+
+import "syscall"
+
+// FreeSpace
+// @description 获取路径所在文件系统中非特权用户可用的空间，单位: 字节
+func FreeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || dragonfly)
+
+package file_op
+
+import "errors"
+
+// FreeSpace
+// @description 当前平台不支持获取可用空间，调用方应当忽略该检查
+func FreeSpace(path string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
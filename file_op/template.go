@@ -0,0 +1,110 @@
+package file_op
+
+import (
+	"strings"
+	"time"
+)
+
+// IsTemplate
+// @description 判断路径是否为模板，只有包含 %Y %m %d %H %M、{date} 或者 vars 中的变量 (例如 {service})
+//				时才是模板，文件名中普通的 '%' 和 '{' 不影响
+func IsTemplate(path string, vars ...string) bool {
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '%':
+			if i+1 < len(path) && strings.IndexByte("YmdHM", path[i+1]) >= 0 {
+				return true
+			}
+		case '{':
+			end := strings.IndexByte(path[i:], '}')
+			if end < 0 {
+				return false
+			}
+			name := path[i+1 : i+end]
+			if name == "date" {
+				return true
+			}
+			for _, v := range vars {
+				if name == v {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// SetTemplate
+// @description 设置日志路径模板，支持 %Y %m %d %H %M、{date} (yyyy-MM-dd) 和 vars 中的变量
+//				(例如 {service})，每次写入前按照当前时间重新计算路径，路径变化时切换到新的文件，
+//				不存在的目录会自动创建
+func (fo *FileOp) SetTemplate(template string, vars map[string]string) *FileOp {
+	fo.template = template
+	fo.vars = vars
+	return fo
+}
+
+// renderPath
+// @description 按照时间和变量生成日志路径，无法识别的占位符原样保留
+func renderPath(template string, t time.Time, vars map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == '%' && i+1 < len(template):
+			i++
+			switch template[i] {
+			case 'Y':
+				b.WriteString(t.Format("2006"))
+			case 'm':
+				b.WriteString(t.Format("01"))
+			case 'd':
+				b.WriteString(t.Format("02"))
+			case 'H':
+				b.WriteString(t.Format("15"))
+			case 'M':
+				b.WriteString(t.Format("04"))
+			case '%':
+				b.WriteByte('%')
+			default:
+				b.WriteByte('%')
+				b.WriteByte(template[i])
+			}
+		case c == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				b.WriteString(template[i:])
+				return b.String()
+			}
+			name := template[i+1 : i+end]
+			if name == "date" {
+				b.WriteString(t.Format("2006-01-02"))
+			} else if v, ok := vars[name]; ok {
+				b.WriteString(v)
+			} else {
+				b.WriteString(template[i : i+end+1])
+			}
+			i += end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// switchPath
+// @description 按照模板重新计算路径，路径变化时关闭当前文件并打开新的文件，返回是否切换
+func (fo *FileOp) switchPath() (bool, error) {
+	if len(fo.template) == 0 {
+		return false, nil
+	}
+	path := renderPath(fo.template, fo.clock.Now(), fo.vars)
+	if path == fo.path {
+		return false, nil
+	}
+
+	if fo.isOpen {
+		_ = fo.Close()
+	}
+	return true, fo.ready()
+}
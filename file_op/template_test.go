@@ -0,0 +1,67 @@
+package file_op
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenderPath(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2022, 6, 4, 9, 5, 0, 0, time.Local)
+	vars := map[string]string{"service": "api"}
+	a.Equal("logs/2022/06/04/app-09.log", renderPath("logs/%Y/%m/%d/app-%H.log", now, vars))
+	a.Equal("logs/2022-06-04/api.log", renderPath("logs/{date}/{service}.log", now, vars))
+	a.Equal("logs/0905-100%-%x-{host}.log", renderPath("logs/%H%M-100%%-%x-{host}.log", now, vars), "无法识别的占位符应该原样保留")
+	a.Equal("logs/{date.log", renderPath("logs/{date.log", now, vars))
+}
+
+func TestIsTemplate(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(IsTemplate("logs/%Y/%m/app.log"))
+	a.True(IsTemplate("logs/app-%H%M.log"))
+	a.True(IsTemplate("logs/{date}/app.log"))
+	a.True(IsTemplate("logs/{service}.log", "service"))
+	a.True(IsTemplate("logs/{x}/{date}.log"))
+
+	a.False(IsTemplate("logs/app.log"))
+	a.False(IsTemplate("logs/100%.log"), "普通的 % 不是模板")
+	a.False(IsTemplate("logs/100%%-%x.log"), "无法识别的占位符不是模板")
+	a.False(IsTemplate("logs/{backup}.log"), "未知的变量不是模板")
+	a.False(IsTemplate("logs/{service}.log"), "没有传入的变量不是模板")
+	a.False(IsTemplate("logs/{date.log"))
+}
+
+func TestTemplatePath(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 14, 23, 30, 0, 0, time.Local))
+	fileOp := CreateFileOp(filepath.Join(dir, "%Y/%m/%d/app-%H.log"), 10, false).
+		SetClock(clock).
+		SetTemplate(filepath.Join(dir, "%Y/%m/%d/app-%H.log"), nil)
+
+	a.Nil(fileOp.Write([]byte("23 o'clock")))
+	clock.Add(10 * time.Minute)
+	a.Nil(fileOp.Write([]byte("still 23 o'clock")))
+	// 跨过整点和日期，自动创建新的目录
+	clock.Add(30 * time.Minute)
+	a.Nil(fileOp.Write([]byte("0 o'clock")))
+	a.Nil(fileOp.Close())
+
+	content, err := os.ReadFile(filepath.Join(dir, "2022/06/14/app-23.log"))
+	a.Nil(err, err)
+	a.Equal("23 o'clock\nstill 23 o'clock\n", string(content))
+
+	content, err = os.ReadFile(filepath.Join(dir, "2022/06/15/app-00.log"))
+	a.Nil(err, err)
+	a.Equal("0 o'clock\n", string(content))
+
+	backups, err := fileOp.listBackups()
+	a.Nil(err, err)
+	a.Empty(backups, "路径切换不应该产生历史日志")
+}
@@ -0,0 +1,175 @@
+package file_op
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// RotateWriter
+// @description 可以单独使用的滚动写入文件，实现 io.WriteCloser，可以在多个协程中并发写入，
+//				写入的数据原样保存，不会追加换行，文件超过 maxSize 或者跨天时切分，
+//				例如用于 net/http 的访问日志或者子进程的输出
+type RotateWriter struct {
+	mu sync.Mutex
+	fo *FileOp
+}
+
+var _ io.WriteCloser = (*RotateWriter)(nil)
+
+// NewRotateWriter
+// @description 创建滚动写入文件，默认单个文件最大 10MB，不压缩，不清理历史日志
+func NewRotateWriter(path string) *RotateWriter {
+	return &RotateWriter{fo: CreateFileOp(path, 10, false)}
+}
+
+// SetMaxSize
+// @description 设置单个文件最大容量，以 MB 为单位
+func (w *RotateWriter) SetMaxSize(maxSize int) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if maxSize > 0 {
+		w.fo.maxSize = maxSize
+	}
+	return w
+}
+
+// SetMaxLines
+// @description 设置单个文件最多的行数，按照写入数据中的换行计算，0 表示不限制
+func (w *RotateWriter) SetMaxLines(maxLines int) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetMaxLines(maxLines)
+	return w
+}
+
+// SetRotateDaily
+// @description 设置是否按日期切分，跨天后第一次写入前切分
+func (w *RotateWriter) SetRotateDaily(daily bool) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetRotateDaily(daily)
+	return w
+}
+
+// SetCompress
+// @description 设置切分后是否压缩历史日志
+func (w *RotateWriter) SetCompress(compress bool) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.needCompress = compress
+	return w
+}
+
+// SetMaxBackups
+// @description 设置最多保留的历史日志个数，0 表示不限制
+func (w *RotateWriter) SetMaxBackups(maxBackups int) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetMaxBackups(maxBackups)
+	return w
+}
+
+// SetMaxAge
+// @description 设置历史日志最多保留天数，0 表示不限制
+func (w *RotateWriter) SetMaxAge(maxAge int) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetMaxAge(maxAge)
+	return w
+}
+
+// SetClock
+// @description 设置时钟
+func (w *RotateWriter) SetClock(clock Clock) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetClock(clock)
+	return w
+}
+
+// SetCheckInterval
+// @description 设置检查日志文件是否被移动或者删除的间隔，0 表示不检查
+func (w *RotateWriter) SetCheckInterval(interval time.Duration) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetCheckInterval(interval)
+	return w
+}
+
+// SetTemplate
+// @description 设置日志路径模板，例如 logs/%Y/%m/%d/access-%H.log
+func (w *RotateWriter) SetTemplate(template string, vars map[string]string) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetTemplate(template, vars)
+	return w
+}
+
+// SetLayout
+// @description 设置日志文件布局
+func (w *RotateWriter) SetLayout(layout Layout) *RotateWriter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.SetLayout(layout)
+	return w
+}
+
+// Write
+// @description 写入数据，第一次写入时打开文件
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.fo.write(p)
+}
+
+// Sync
+// @description 将已经写入的数据同步到磁盘
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.fo.Sync()
+}
+
+// Rotate
+// @description 立即切分日志文件，例如收到 SIGHUP 信号时
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.fo.Rotate()
+}
+
+// Reopen
+// @description 重新打开日志文件，用于外部工具移动日志文件之后
+func (w *RotateWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.fo.Reopen()
+}
+
+// Close
+// @description 关闭文件并等待历史日志压缩完成，之后再写入时会重新打开
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fo.WaitCompress()
+	if !w.fo.isOpen {
+		return nil
+	}
+	return w.fo.Close()
+}
@@ -0,0 +1,79 @@
+package file_op
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotateWriterConcurrent(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	w := NewRotateWriter(filepath.Join(dir, "access.log")).SetMaxSize(1)
+
+	// 8 个协程各写入 200 行 1KB 的数据，总量超过 1MB，至少切分一次
+	line := strings.Repeat("x", 1023) + "\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				n, err := w.Write([]byte(line))
+				a.Nil(err, err)
+				a.Equal(len(line), n)
+			}
+		}()
+	}
+	wg.Wait()
+	a.Nil(w.Close())
+
+	backups, err := w.fo.listBackups()
+	a.Nil(err, err)
+	a.NotEmpty(backups, "超过 maxSize 后应该切分")
+
+	var all []byte
+	for _, b := range append(backups, backupFile{path: filepath.Join(dir, "access.log")}) {
+		content, err := os.ReadFile(b.path)
+		a.Nil(err, err)
+		all = append(all, content...)
+	}
+	a.Equal(8*200*len(line), len(all), "写入的数据不能丢失，也不能追加换行")
+	a.Equal(bytes.Repeat([]byte(line), 8*200), all, "并发写入的数据不能交错")
+}
+
+func TestRotateWriterRotate(t *testing.T) {
+	a := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 14, 12, 0, 0, 0, time.Local))
+	w := NewRotateWriter(filepath.Join(dir, "app.log")).SetClock(clock).SetCompress(true).SetMaxBackups(5)
+
+	for i := 0; i < 3; i++ {
+		_, err := fmt.Fprintf(w, "part %d", i)
+		a.Nil(err, err)
+		a.Nil(w.Rotate(), "强制切分失败")
+	}
+	_, err := w.Write([]byte("current"))
+	a.Nil(err, err)
+	a.Nil(w.Close())
+	a.Nil(w.Close(), "重复关闭不应该返回错误")
+
+	backups, err := w.fo.listBackups()
+	a.Nil(err, err)
+	a.Len(backups, 3, "同一秒内多次切分不能覆盖历史日志")
+	for _, b := range backups {
+		a.True(strings.HasSuffix(b.path, ".zip"), "历史日志应该被压缩")
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	a.Nil(err, err)
+	a.Equal("current", string(content))
+}
@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ConsoleFormat 控制台输出格式
+type ConsoleFormat byte
+
+// LogConsoleFormat 控制台输出格式选项
+var LogConsoleFormat = struct {
+	JSON    ConsoleFormat // 每行一条 Json 日志
+	Pretty  ConsoleFormat // 便于阅读的格式，适用于开发环境，输出不是终端时仍然使用 Json
+	Default ConsoleFormat
+}{0, 1, 0}
+
+// 日志级别对应的 ANSI 颜色
+var levelColors = []string{
+	0: "\x1b[90m", // TRACE 灰色
+	1: "\x1b[36m", // DEBUG 青色
+	2: "\x1b[32m", // INFO 绿色
+	3: "\x1b[33m", // WARN 黄色
+	4: "\x1b[31m", // ERROR 红色
+	5: "\x1b[35m", // PANIC 紫色
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+	colorBold  = "\x1b[1m"
+
+	maxCallerWidth = 40 // 调用位置列最大宽度，超过后不再对齐
+)
+
+// consoleWriter
+// @author Tianyi
+// @description 控制台输出，Pretty 格式按照 "时间 级别 调用位置 名称 信息 字段" 对齐输出，
+//				调用位置只保留最后一级目录，颜色只在终端中并且没有设置 NO_COLOR 时开启
+type consoleWriter struct {
+	out    io.Writer
+	schema *EntrySchema
+	pretty bool
+	color  bool
+
+	mu          sync.Mutex
+	callerWidth int // 已经输出过的调用位置最大宽度，用于对齐
+}
+
+// newConsoleWriter
+// @author Tianyi
+// @description 构建标准输出的控制台输出
+func newConsoleWriter(cfg *YiLogConfig) *consoleWriter {
+	tty := isTerminal(os.Stdout)
+	return &consoleWriter{
+		out:    os.Stdout,
+		schema: cfg.Schema,
+		pretty: cfg.Console == LogConsoleFormat.Pretty && tty,
+		color:  tty && len(os.Getenv("NO_COLOR")) == 0,
+	}
+}
+
+// isTerminal
+// @author Tianyi
+// @description 判断文件是否为终端 (字符设备)，管道和普通文件返回 false
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// print
+// @author Tianyi
+// @description 输出一条日志，整行一次写入，避免多个协程的输出交错
+func (w *consoleWriter) print(entry *yiLogEntry) {
+	var line []byte
+	if w.pretty {
+		line = w.format(entry)
+	} else {
+		line = append(w.schema.encode(entry), '\n')
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = w.out.Write(line)
+}
+
+// format
+// @author Tianyi
+// @description 生成 Pretty 格式的一行日志
+func (w *consoleWriter) format(entry *yiLogEntry) []byte {
+	var buf bytes.Buffer
+
+	w.paint(&buf, colorDim, fmt.Sprint(entry.DateTime))
+	buf.WriteByte(' ')
+
+	level := entry.Level
+	if int(entry.lvl) < len(levelColors) {
+		w.paint(&buf, levelColors[entry.lvl], fmt.Sprintf("%-5s", level))
+	} else {
+		buf.WriteString(fmt.Sprintf("%-5s", level))
+	}
+	buf.WriteByte(' ')
+
+	caller := shortCaller(entry.Trace, entry.Line)
+	width := utf8.RuneCountInString(caller)
+	w.mu.Lock()
+	if width > w.callerWidth && width <= maxCallerWidth {
+		w.callerWidth = width
+	}
+	pad := w.callerWidth - width
+	w.mu.Unlock()
+	w.paint(&buf, colorDim, caller)
+	if pad > 0 {
+		buf.WriteString(strings.Repeat(" ", pad))
+	}
+	buf.WriteByte(' ')
+
+	if len(entry.Name) != 0 {
+		w.paint(&buf, colorBold, entry.Name+":")
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(entry.Message)
+
+	if entry.Repeated != 0 {
+		w.field(&buf, "repeated", entry.Repeated)
+	}
+	if len(entry.TraceID) != 0 {
+		w.field(&buf, "trace_id", entry.TraceID)
+		w.field(&buf, "span_id", entry.SpanID)
+	}
+	for _, f := range entry.Fields {
+		w.field(&buf, f.Key, f.Value)
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// field
+// @author Tianyi
+// @description 以 key=value 的形式输出一个字段，包含空格或者引号的字符串加上引号，
+//				其他类型序列化为 Json
+func (w *consoleWriter) field(buf *bytes.Buffer, key string, value any) {
+	buf.WriteByte(' ')
+	w.paint(buf, levelColors[LogLevel.DebugLevel], key+"=")
+
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			b = []byte(fmt.Sprint(v))
+		}
+		buf.Write(b)
+		return
+	}
+	if len(s) == 0 || strings.ContainsAny(s, " \t\"=") {
+		s = strconv.Quote(s)
+	}
+	buf.WriteString(s)
+}
+
+// paint
+// @author Tianyi
+// @description 开启颜色时使用指定颜色输出文本
+func (w *consoleWriter) paint(buf *bytes.Buffer, color string, s string) {
+	if !w.color {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteString(color)
+	buf.WriteString(s)
+	buf.WriteString(colorReset)
+}
+
+// shortCaller
+// @author Tianyi
+// @description 只保留文件路径的最后一级目录，例如 /a/b/logger/logger.go -> logger/logger.go
+func shortCaller(trace string, line int) string {
+	idx := strings.LastIndexByte(trace, '/')
+	if idx > 0 {
+		if prev := strings.LastIndexByte(trace[:idx], '/'); prev >= 0 {
+			trace = trace[prev+1:]
+		}
+	}
+	return trace + ":" + strconv.Itoa(line)
+}
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsolePretty(t *testing.T) {
+	ass := assert.New(t)
+
+	l, err := BuildLoggerLink().SetConsole(LogConsoleFormat.Pretty).BuildE()
+	ass.Nil(err, err)
+	ass.False(l.console.pretty, "输出不是终端时应该使用 Json")
+	ass.False(l.console.color, "输出不是终端时不应该开启颜色")
+
+	var buf bytes.Buffer
+	w := &consoleWriter{out: &buf, schema: l.cfg.Schema, pretty: true}
+
+	now := time.Date(2026, 10, 19, 8, 30, 0, 0, time.Local)
+	entry := newLogEntry(l.cfg, LogLevel.WarnLevel, "slow query", now, "/home/app/src/db/query.go", 42)
+	entry.Name = "db"
+	entry.Fields = []Field{{"table", "orders"}, {"sql", "select 1"}, {"rows", 3}, {"err", errors.New("timeout")}}
+	w.print(entry)
+
+	short := newLogEntry(l.cfg, LogLevel.InfoLevel, "ok", now, "/main.go", 7)
+	w.print(short)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	ass.Len(lines, 2)
+	ass.Equal(`2026-10-19 08:30:00 WARN  db/query.go:42 db: slow query table=orders sql="select 1" rows=3 err=timeout`, lines[0])
+	ass.Equal(`2026-10-19 08:30:00 INFO  /main.go:7     ok`, lines[1], "调用位置应该对齐")
+
+	buf.Reset()
+	w.color = true
+	w.print(entry)
+	ass.True(strings.HasPrefix(buf.String(), "\x1b[2m2026-10-19 08:30:00\x1b[0m \x1b[33mWARN \x1b[0m"), "WARN 应该使用黄色")
+}
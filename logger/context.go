@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"context"
+	"os"
+)
+
+// SpanExtractor
+// @author Tianyi
+// @description 从 context 中获取链路追踪的 trace_id 和 span_id (十六进制字符串)，
+//				使用 OpenTelemetry 时可以通过 trace.SpanContextFromContext 实现
+type SpanExtractor func(ctx context.Context) (traceID, spanID string, ok bool)
+
+// spanKey context 中保存 span 信息的 key
+type spanKey struct{}
+
+// spanInfo 通过 ContextWithSpan 保存的 span 信息
+type spanInfo struct {
+	traceID string
+	spanID  string
+}
+
+// ContextWithSpan
+// @author Tianyi
+// @description 将 trace_id 和 span_id 保存到 context 中，没有使用 OpenTelemetry 时
+//				可以通过它关联日志和链路
+func ContextWithSpan(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, spanKey{}, spanInfo{traceID: traceID, spanID: spanID})
+}
+
+// spanFromContext
+// @author Tianyi
+// @description 默认的 SpanExtractor，读取 ContextWithSpan 保存的信息
+func spanFromContext(ctx context.Context) (string, string, bool) {
+	info, ok := ctx.Value(spanKey{}).(spanInfo)
+	if !ok || len(info.traceID) == 0 {
+		return "", "", false
+	}
+	return info.traceID, info.spanID, true
+}
+
+// withSpan
+// @author Tianyi
+// @description 从 context 中获取 span 信息并添加到日志中
+func (logger *yiLogger) withSpan(ctx context.Context, entry *yiLogEntry) {
+	if ctx == nil {
+		return
+	}
+	extractor := logger.cfg.SpanExtractor
+	if extractor == nil {
+		extractor = spanFromContext
+	}
+	if traceID, spanID, ok := extractor(ctx); ok {
+		entry.TraceID = traceID
+		entry.SpanID = spanID
+	}
+}
+
+func (logger *yiLogger) TraceCtx(ctx context.Context, format string, a ...any) {
+	if LogLevel.TraceLevel < logger.cfg.LogLevel {
+		return
+	}
+
+	entry := logger.makeLog(LogLevel.TraceLevel, format, a...)
+	logger.withSpan(ctx, entry)
+
+	logger.output(entry)
+}
+
+func (logger *yiLogger) DebugCtx(ctx context.Context, format string, a ...any) {
+	if LogLevel.DebugLevel < logger.cfg.LogLevel {
+		return
+	}
+
+	entry := logger.makeLog(LogLevel.DebugLevel, format, a...)
+	logger.withSpan(ctx, entry)
+
+	logger.output(entry)
+}
+
+func (logger *yiLogger) InfoCtx(ctx context.Context, format string, a ...any) {
+	if LogLevel.InfoLevel < logger.cfg.LogLevel {
+		return
+	}
+
+	entry := logger.makeLog(LogLevel.InfoLevel, format, a...)
+	logger.withSpan(ctx, entry)
+
+	logger.output(entry)
+}
+
+func (logger *yiLogger) WarnCtx(ctx context.Context, format string, a ...any) {
+	if LogLevel.WarnLevel < logger.cfg.LogLevel {
+		return
+	}
+
+	entry := logger.makeLog(LogLevel.WarnLevel, format, a...)
+	logger.withSpan(ctx, entry)
+
+	logger.output(entry)
+}
+
+func (logger *yiLogger) ErrorCtx(ctx context.Context, format string, a ...any) {
+	if LogLevel.ErrorLevel < logger.cfg.LogLevel {
+		return
+	}
+
+	entry := logger.makeLog(LogLevel.ErrorLevel, format, a...)
+	logger.withSpan(ctx, entry)
+
+	logger.output(entry)
+}
+
+// PanicCtx
+// @author Tianyi
+// @description 该日志级别会直接让整个程序退出，慎用
+func (logger *yiLogger) PanicCtx(ctx context.Context, format string, a ...any) {
+	if LogLevel.PanicLevel < logger.cfg.LogLevel {
+		return
+	}
+
+	entry := logger.makeLog(LogLevel.PanicLevel, format, a...)
+	logger.withSpan(ctx, entry)
+
+	logger.output(entry)
+
+	os.Exit(1)
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"reflect"
+	"time"
+)
+
+// deduper
+// @author Tianyi
+// @description 合并连续重复的日志，级别、信息、调用位置、结构化字段和链路追踪信息都相同的日志视为重复，
+//				重复的日志会被合并成一条带有 repeated 字段的日志输出
+type deduper struct {
+	window  time.Duration // 合并窗口，超过该时间未结束的重复日志会被强制输出
+	pending *yiLogEntry   // 当前正在合并的日志
+	last    *yiLogEntry   // 最后一条重复日志，用于记录结束时间
+	count   int           // 重复次数
+}
+
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{window: window}
+}
+
+// push
+// @author Tianyi
+// @description 放入一条日志，返回需要立即输出的日志
+func (d *deduper) push(entry *yiLogEntry) []*yiLogEntry {
+	if d.pending != nil && d.pending.sameAs(entry) {
+		d.count++
+		d.last = entry
+		return nil
+	}
+
+	out := d.flush()
+	d.pending = entry
+	d.last = entry
+	d.count = 1
+	return out
+}
+
+// expire
+// @author Tianyi
+// @description 判断当前合并的日志是否超过时间窗口，超过则输出
+func (d *deduper) expire(now time.Time) []*yiLogEntry {
+	if d.pending == nil || now.Sub(d.pending.at) < d.window {
+		return nil
+	}
+	return d.flush()
+}
+
+// flush
+// @author Tianyi
+// @description 结束当前的合并，返回合并后的日志
+func (d *deduper) flush() []*yiLogEntry {
+	if d.pending == nil {
+		return nil
+	}
+
+	entry := d.pending
+	if d.count > 1 {
+		entry.Repeated = d.count
+		entry.FirstTime = entry.DateTime
+		entry.LastTime = d.last.DateTime
+	}
+
+	d.pending = nil
+	d.last = nil
+	d.count = 0
+	return []*yiLogEntry{entry}
+}
+
+// sameAs
+// @author Tianyi
+// @description 判断两条日志是否重复，日志名称、结构化字段和链路追踪信息不同的日志不视为重复
+func (entry *yiLogEntry) sameAs(other *yiLogEntry) bool {
+	return entry.Name == other.Name &&
+		entry.Level == other.Level &&
+		entry.Message == other.Message &&
+		entry.Trace == other.Trace &&
+		entry.Line == other.Line &&
+		entry.TraceID == other.TraceID &&
+		entry.SpanID == other.SpanID &&
+		reflect.DeepEqual(entry.Fields, other.Fields)
+}
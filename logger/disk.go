@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Chentyit/yi-logger/file_op"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// diskCheckInterval 检查磁盘可用空间的间隔
+const diskCheckInterval = time.Second
+
+// errLowFreeSpace 可用空间不足时丢弃日志
+var errLowFreeSpace = errors.New("free space is below the minimum, log dropped")
+
+// diskGuard
+// @author Tianyi
+// @description 磁盘可用空间保护，可用空间低于 MinFreeSpace 时丢弃 FreeSpaceLevel 以下的日志，
+//				避免日志写满磁盘影响主机上的其他服务，无法获取可用空间时不做限制
+type diskGuard struct {
+	minFree   uint64                            // 最小可用空间，单位: 字节
+	level     Level                             // 可用空间不足时仍然写入的最低日志级别
+	path      func() string                     // 日志文件路径
+	freeSpace func(path string) (uint64, error) // 获取可用空间
+	lastCheck time.Time
+	low       bool // 可用空间是否低于 minFree
+}
+
+// newDiskGuard
+// @author Tianyi
+// @description 创建磁盘可用空间保护，MinFreeSpace 小于等于 0 时返回 nil
+func newDiskGuard(cfg *YiLogConfig, path func() string) *diskGuard {
+	if cfg.MinFreeSpace <= 0 {
+		return nil
+	}
+	level := LogLevel.ErrorLevel
+	if cfg.FreeSpaceLevel != nil {
+		level = *cfg.FreeSpaceLevel
+	}
+	return &diskGuard{
+		minFree:   uint64(cfg.MinFreeSpace) * 1024 * 1024,
+		level:     level,
+		path:      path,
+		freeSpace: file_op.FreeSpace,
+	}
+}
+
+// check
+// @author Tianyi
+// @description 按照 diskCheckInterval 检查可用空间，返回当前是否低于阈值，以及是否刚刚低于阈值
+func (g *diskGuard) check(now time.Time) (low, crossed bool) {
+	if !g.lastCheck.IsZero() && now.Sub(g.lastCheck) < diskCheckInterval {
+		return g.low, false
+	}
+	g.lastCheck = now
+
+	free, err := g.freeSpace(existingDir(g.path()))
+	wasLow := g.low
+	g.low = err == nil && free < g.minFree
+	return g.low, g.low && !wasLow
+}
+
+// allow
+// @author Tianyi
+// @description 判断日志是否可以写入，刚刚低于阈值时返回一条提示日志，每次空间不足只提示一次
+func (g *diskGuard) allow(cfg *YiLogConfig, entry *yiLogEntry) (bool, *yiLogEntry) {
+	now := cfg.now()
+	low, crossed := g.check(now)
+	var warn *yiLogEntry
+	if crossed {
+		msg := fmt.Sprintf("free space of %s is below %dMB, dropping logs below %s",
+			existingDir(g.path()), cfg.MinFreeSpace, logLevel[g.level])
+		warn = newLogEntry(cfg, LogLevel.WarnLevel, msg, now, "yi-logger", 0)
+	}
+	return !low || entry.lvl >= g.level, warn
+}
+
+// existingDir
+// @author Tianyi
+// @description 获取日志文件所在目录中已经存在的最近一级目录，目录可能还没有创建或者包含路径模板
+func existingDir(path string) string {
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
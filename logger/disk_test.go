@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMinFreeSpace(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	clock := NewFakeClock(time.Date(2022, 6, 14, 12, 0, 0, 0, time.Local))
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetClock(clock).
+		SetMinFreeSpace(100, LogLevel.ErrorLevel).
+		BuildE()
+	ass.Nil(err, err)
+
+	// 模拟磁盘可用空间
+	var free atomic.Uint64
+	free.Store(1 << 30)
+	l.guard.freeSpace = func(string) (uint64, error) {
+		return free.Load(), nil
+	}
+
+	// Reopen 返回时之前的日志已经写完，保证检查可用空间的顺序
+	step := func(space uint64) {
+		ass.Nil(l.Reopen())
+		free.Store(space)
+		clock.Add(2 * time.Second)
+	}
+
+	l.Info("enough space")
+	step(10 << 20)
+	l.Info("dropped 1")
+	l.Error("kept 1")
+	l.Warn("dropped 2")
+	step(1 << 30)
+	l.Info("recovered")
+	step(10 << 20)
+	l.Info("dropped 3")
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	log := string(content)
+	ass.Contains(log, `"message":"enough space"`)
+	ass.Contains(log, `"message":"kept 1"`, "ERROR 及以上的日志应该继续写入")
+	ass.Contains(log, `"message":"recovered"`, "可用空间恢复后应该继续写入")
+	ass.NotContains(log, "dropped", "可用空间不足时应该丢弃低级别的日志")
+	ass.Equal(2, strings.Count(log, "free space of "+dir+" is below 100MB"), "每次可用空间不足只提示一次")
+}
+
+func TestMinFreeSpaceConfig(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(dir, "app.log")).
+		SetMinFreeSpace(100, LogLevel.TraceLevel).
+		BuildE()
+	ass.Nil(err, err)
+	ass.Equal(LogLevel.TraceLevel, l.guard.level, "明确设置的 TRACE 不应该被改成默认值")
+	l.Close()
+
+	l, err = New(&YiLogConfig{
+		OutputWay:    OutPut.File,
+		File:         filepath.Join(dir, "app.log"),
+		MinFreeSpace: 100,
+	})
+	ass.Nil(err, err)
+	ass.Equal(LogLevel.ErrorLevel, l.guard.level, "默认只写入 ERROR 及以上的日志")
+	l.Close()
+
+	_, err = BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(dir, "app.log")).
+		SetMinFreeSpace(100, Level(9)).
+		BuildE()
+	ass.NotNil(err, "日志级别错误时应该返回错误")
+
+	// 目录还没有创建时检查最近一级存在的目录
+	ass.Equal(dir, existingDir(filepath.Join(dir, "{date}", "a", "app.log")))
+}
@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// FluentConfig
+// @author Tianyi
+// @description Fluentd Forward 协议输出配置，可以直接发送到 fluentd 或者 fluent-bit
+type FluentConfig struct {
+	Network      string        // 传输方式: tcp、unix (默认: tcp)
+	Address      string        // 地址 (默认: 127.0.0.1:24224)
+	Tag          string        // tag，带名称的 Logger 使用 "Tag.名称" (默认: yi-logger)
+	RequireAck   bool          // 是否要求服务端确认，未确认的日志会重新发送
+	AckTimeout   time.Duration // 等待确认的超时时间 (默认: 10s)
+	BatchSize    int           // 每个 PackedForward 消息最多包含的日志条数 (默认: 100)
+	BufferSize   int           // 断开连接时最多缓存的日志条数，超过后丢弃最早的日志 (默认: 10000)
+	DialTimeout  time.Duration // 连接超时时间 (默认: 5s)
+	WriteTimeout time.Duration // 写入超时时间 (默认: 5s)
+	MinBackoff   time.Duration // 第一次重连的等待时间，之后每次翻倍 (默认: 100ms)
+	MaxBackoff   time.Duration // 重连最长等待时间 (默认: 30s)
+}
+
+// fluentSink
+// @author Tianyi
+// @description 使用 Forward 协议的 PackedForward 模式发送日志，相同 tag 的连续日志
+//				打包成一条消息，发送在单独的协程中完成，断开后按照指数退避重连
+type fluentSink struct {
+	*asyncQueue
+	cfg    FluentConfig
+	conn   net.Conn      // 只在发送协程中使用
+	reader *bufio.Reader // 读取 ack 响应
+}
+
+// newFluentSink
+// @author Tianyi
+// @description 构建 Fluentd 输出目标并启动发送协程
+func newFluentSink(cfg *FluentConfig) (*fluentSink, error) {
+	var c FluentConfig
+	if cfg != nil {
+		c = *cfg
+	}
+
+	if len(c.Network) == 0 {
+		c.Network = "tcp"
+	}
+	switch c.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported fluent network: %s", c.Network)
+	}
+	if len(c.Address) == 0 {
+		if c.Network == "unix" {
+			return nil, errors.New("fluent unix socket path is required")
+		}
+		c.Address = "127.0.0.1:24224"
+	}
+	if len(c.Tag) == 0 {
+		c.Tag = "yi-logger"
+	}
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = 10 * time.Second
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 10000
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 5 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 5 * time.Second
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 30 * time.Second
+	}
+
+	s := &fluentSink{
+		asyncQueue: newAsyncQueue(c.Address, c.BufferSize, false, func(_ *yiLogEntry, log []byte) []byte {
+			return log
+		}),
+		cfg: c,
+	}
+	go s.run(s.flush, c.MinBackoff, c.MaxBackoff)
+	return s, nil
+}
+
+// tag
+// @author Tianyi
+// @description 根据 logger 名称生成 tag
+func (s *fluentSink) tag(name string) string {
+	if len(name) == 0 {
+		return s.cfg.Tag
+	}
+	return s.cfg.Tag + "." + name
+}
+
+func (s *fluentSink) write(entry *yiLogEntry, log []byte) error {
+	// 使用序列化后的 Json 作为 record，保证字段和其他输出方式一致
+	if !json.Valid(log) {
+		return errors.New("fluent: log is not valid json")
+	}
+	s.push(entry, log)
+	s.notify()
+	return nil
+}
+
+func (s *fluentSink) close() error {
+	s.shutdown()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// flush
+// @author Tianyi
+// @description 按照 tag 和 BatchSize 分批发送所有日志，失败时未发送的日志放回缓冲区
+func (s *fluentSink) flush() error {
+	items := s.take(0)
+	for len(items) != 0 {
+		tag := s.tag(items[0].entry.Name)
+		n := 1
+		for n < len(items) && n < s.cfg.BatchSize && s.tag(items[n].entry.Name) == tag {
+			n++
+		}
+		if err := s.send(tag, items[:n]); err != nil {
+			if s.conn != nil {
+				_ = s.conn.Close()
+				s.conn = nil
+			}
+			s.requeue(items)
+			return err
+		}
+		s.sent(items[:n], nil)
+		items = items[n:]
+	}
+	return nil
+}
+
+// send
+// @author Tianyi
+// @description 发送一条 PackedForward 消息: [tag, entries, option]，
+//				开启确认时等待服务端返回 {"ack": chunk}
+func (s *fluentSink) send(tag string, items []queued) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Address, s.cfg.DialTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.reader = bufio.NewReader(conn)
+	}
+
+	entries := &msgpackEncoder{}
+	for _, item := range items {
+		var record map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(item.data))
+		decoder.UseNumber()
+		_ = decoder.Decode(&record)
+		entries.encodeArrayHeader(2)
+		entries.encodeEventTime(item.entry.at)
+		entries.encode(record)
+	}
+
+	option := map[string]any{"size": len(items)}
+	var chunk string
+	if s.cfg.RequireAck {
+		id := make([]byte, 16)
+		_, _ = rand.Read(id)
+		chunk = base64.StdEncoding.EncodeToString(id)
+		option["chunk"] = chunk
+	}
+
+	msg := &msgpackEncoder{}
+	msg.encodeArrayHeader(3)
+	msg.encodeString(tag)
+	msg.encodeBin(entries.buf)
+	msg.encode(option)
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+	if _, err := s.conn.Write(msg.buf); err != nil {
+		return err
+	}
+	if !s.cfg.RequireAck {
+		return nil
+	}
+
+	_ = s.conn.SetReadDeadline(time.Now().Add(s.cfg.AckTimeout))
+	resp, err := msgpackDecode(s.reader)
+	if err != nil {
+		return err
+	}
+	if m, ok := resp.(map[string]any); !ok || m["ack"] != chunk {
+		return errors.New("fluent: ack mismatch")
+	}
+	return nil
+}
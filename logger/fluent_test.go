@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeForwardServer 进程内的 Fluentd Forward 服务，解析 PackedForward 消息并返回 ack
+type fakeForwardServer struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	records map[string][]map[string]any // tag -> records
+	times   []msgpackExt
+}
+
+func newFakeForwardServer(t *testing.T) *fakeForwardServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeForwardServer{ln: ln, records: map[string][]map[string]any{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *fakeForwardServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		v, err := msgpackDecode(reader)
+		if err != nil {
+			return
+		}
+		msg := v.([]any)
+		tag := msg[0].(string)
+		entries := bufio.NewReader(bytes.NewReader(msg[1].([]byte)))
+		option := msg[2].(map[string]any)
+
+		srv.mu.Lock()
+		for i := int64(0); i < option["size"].(int64); i++ {
+			e, err := msgpackDecode(entries)
+			if err != nil {
+				break
+			}
+			pair := e.([]any)
+			srv.times = append(srv.times, pair[0].(msgpackExt))
+			srv.records[tag] = append(srv.records[tag], pair[1].(map[string]any))
+		}
+		srv.mu.Unlock()
+
+		if chunk, ok := option["chunk"]; ok {
+			ack := &msgpackEncoder{}
+			ack.encode(map[string]any{"ack": chunk})
+			_, _ = conn.Write(ack.buf)
+		}
+	}
+}
+
+func TestFluentSink(t *testing.T) {
+	ass := assert.New(t)
+
+	srv := newFakeForwardServer(t)
+	defer srv.ln.Close()
+
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.Fluent).
+		SetFluent(&FluentConfig{
+			Address:    srv.ln.Addr().String(),
+			Tag:        "app",
+			RequireAck: true,
+			AckTimeout: time.Second,
+		}).
+		BuildE()
+	ass.Nil(err, err)
+
+	l.Info("root message")
+	l.Named("db").Warn("slow query: %dms", 300)
+	l.Named("db").Named("pool").Error("exhausted")
+	l.Close()
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	ass.Len(srv.records["app"], 1)
+	ass.Equal("root message", srv.records["app"][0]["message"])
+	ass.Len(srv.records["app.db"], 1)
+	ass.Equal("slow query: 300ms", srv.records["app.db"][0]["message"])
+	ass.Equal("db", srv.records["app.db"][0]["logger"])
+	ass.Equal("WARN", srv.records["app.db"][0]["level"])
+	ass.Len(srv.records["app.db.pool"], 1)
+	ass.IsType(int64(0), srv.records["app.db.pool"][0]["line"])
+
+	for _, ts := range srv.times {
+		ass.EqualValues(0, ts.Type, "时间应该使用 EventTime 扩展类型")
+		ass.Len(ts.Data, 8)
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	ass := assert.New(t)
+
+	enc := &msgpackEncoder{}
+	value := map[string]any{
+		"int":    int64(-1000),
+		"small":  int64(7),
+		"float":  1.5,
+		"string": string(bytes.Repeat([]byte("x"), 300)),
+		"bool":   true,
+		"nil":    nil,
+		"array":  []any{int64(1), "two"},
+	}
+	enc.encode(value)
+
+	decoded, err := msgpackDecode(bufio.NewReader(bytes.NewReader(enc.buf)))
+	ass.Nil(err, err)
+	ass.Equal(value, decoded)
+}
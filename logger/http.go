@@ -0,0 +1,382 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Chentyit/yi-logger/file_op"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPFormat HTTP 批量发送的数据格式
+type HTTPFormat byte
+
+// LogHTTPFormat HTTP 批量发送的数据格式选项
+var LogHTTPFormat = struct {
+	NDJSON    HTTPFormat // 每行一条日志，适用于通用 webhook
+	ESBulk    HTTPFormat // Elasticsearch _bulk 接口
+	Loki      HTTPFormat // Loki push 接口 (/loki/api/v1/push)
+	OTLPJSON  HTTPFormat // OpenTelemetry OTLP/HTTP JSON 编码 (/v1/logs)
+	OTLPProto HTTPFormat // OpenTelemetry OTLP/HTTP protobuf 编码 (/v1/logs)
+	Default   HTTPFormat
+}{0, 1, 2, 3, 4, 0}
+
+// HTTPConfig
+// @author Tianyi
+// @description HTTP 输出配置，日志按数量或者时间批量发送
+type HTTPConfig struct {
+	URL           string            // 接收日志的地址
+	Format        HTTPFormat        // 数据格式 (默认: NDJSON)
+	Index         string            // Elasticsearch 索引 (默认: yi-logger)
+	Labels        map[string]string // Loki stream 标签或者 OTLP Resource 属性 (默认: {"app": 程序名称}，OTLP 为 {"service.name": 程序名称})
+	Headers       map[string]string // 额外的请求头，例如认证信息
+	BatchSize     int               // 每批最多日志条数 (默认: 100)
+	FlushInterval time.Duration     // 最长发送间隔 (默认: 1s)
+	Gzip          bool              // 是否使用 gzip 压缩请求体
+	MaxRetries    int               // 失败后最多重试次数 (默认: 3，小于 0 时不重试)
+	RetryBackoff  time.Duration     // 第一次重试的等待时间，之后每次翻倍 (默认: 100ms)
+	Timeout       time.Duration     // 请求超时时间 (默认: 10s)
+	SpoolDir      string            // 发送失败的日志保存目录，恢复后重新发送，为空时丢弃
+	MaxBuffer     int               // 内存中最多缓存的日志条数，超过后丢弃最早的日志 (默认: 10000)
+	CloseTimeout  time.Duration     // 关闭时等待发送完成的最长时间，超过后停止重试，剩余的日志保存到 SpoolDir (默认: 5s)
+}
+
+// httpItem 等待发送的日志
+type httpItem struct {
+	ts    int64
+	log   []byte
+	entry *yiLogEntry // OTLP 格式需要按照字段重新编码
+}
+
+// httpSink
+// @author Tianyi
+// @description 批量发送日志到 HTTP 接口，发送在单独的协程中完成，不会阻塞 writer 协程，
+//				重试仍然失败时将请求体保存到 SpoolDir，下次发送成功后重新发送，
+//				服务端拒绝 (4xx) 的请求体保存为 .rejected 文件，不会重新发送
+type httpSink struct {
+	*asyncQueue // 保存到 SpoolDir 也视为送达
+	cfg         HTTPConfig
+	client      *http.Client
+	ctx         context.Context // 关闭超时后取消，停止正在进行的请求和重试
+	cancel      context.CancelFunc
+}
+
+// newHTTPSink
+// @author Tianyi
+// @description 构建 HTTP 输出目标并启动发送协程
+func newHTTPSink(cfg *HTTPConfig) (*httpSink, error) {
+	if cfg == nil || len(cfg.URL) == 0 {
+		return nil, errors.New("http url is required")
+	}
+	c := *cfg
+
+	if c.Format > LogHTTPFormat.OTLPProto {
+		return nil, fmt.Errorf("invalid http format: %d", c.Format)
+	}
+	if len(c.Index) == 0 {
+		c.Index = "yi-logger"
+	}
+	if len(c.Labels) == 0 {
+		if c.Format == LogHTTPFormat.OTLPJSON || c.Format == LogHTTPFormat.OTLPProto {
+			c.Labels = map[string]string{"service.name": filepath.Base(os.Args[0])}
+		} else {
+			c.Labels = map[string]string{"app": filepath.Base(os.Args[0])}
+		}
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = 100 * time.Millisecond
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.MaxBuffer <= 0 {
+		c.MaxBuffer = 10000
+	}
+	if c.MaxBuffer < c.BatchSize {
+		c.MaxBuffer = c.BatchSize
+	}
+	if c.CloseTimeout <= 0 {
+		c.CloseTimeout = 5 * time.Second
+	}
+	if len(c.SpoolDir) != 0 {
+		if err := file_op.Mkdir(c.SpoolDir); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &httpSink{
+		asyncQueue: newAsyncQueue(c.URL, c.MaxBuffer, false, func(_ *yiLogEntry, log []byte) []byte {
+			return log
+		}),
+		cfg:    c,
+		client: &http.Client{Timeout: c.Timeout},
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.loop()
+	return s, nil
+}
+
+func (s *httpSink) write(entry *yiLogEntry, log []byte) error {
+	// 发送太慢或者接口不可用时丢弃最早的日志，避免占用过多内存
+	if s.push(entry, log) >= s.cfg.BatchSize {
+		s.notify()
+	}
+	return nil
+}
+
+func (s *httpSink) close() error {
+	close(s.exitCh)
+	select {
+	case <-s.doneCh:
+	case <-time.After(s.cfg.CloseTimeout):
+		s.cancel()
+		<-s.doneCh
+	}
+	s.cancel()
+	s.release()
+	return nil
+}
+
+// loop
+// @author Tianyi
+// @description 发送协程，批次已满或者到达发送间隔时发送，启动时先发送之前保存的日志
+func (s *httpSink) loop() {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	s.replay()
+	for {
+		select {
+		case <-s.signal:
+			s.flush()
+		case <-ticker.C:
+			s.flush()
+		case <-s.exitCh:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush
+// @author Tianyi
+// @description 按批次发送当前所有日志，关闭超时后剩余的日志直接保存到 SpoolDir
+func (s *httpSink) flush() {
+	for {
+		items := s.take(s.cfg.BatchSize)
+		if len(items) == 0 {
+			return
+		}
+
+		body := s.encode(httpItems(items))
+		if s.ctx.Err() != nil {
+			s.sent(items, s.spool(body, ".spool"))
+			continue
+		}
+		if retry, err := s.send(body); err != nil {
+			if retry {
+				s.sent(items, s.spool(body, ".spool"))
+			} else {
+				// 服务端拒绝的日志重新发送也不会成功
+				_ = s.spool(body, ".rejected")
+				s.sent(items, nil)
+			}
+			continue
+		}
+		s.sent(items, nil)
+		s.replay()
+	}
+}
+
+// httpItems
+// @author Tianyi
+// @description 转换为生成请求体使用的日志
+func httpItems(items []queued) []httpItem {
+	out := make([]httpItem, 0, len(items))
+	for _, item := range items {
+		out = append(out, httpItem{ts: item.entry.at.UnixNano(), log: item.data, entry: item.entry})
+	}
+	return out
+}
+
+// encode
+// @author Tianyi
+// @description 按照配置的格式生成请求体
+func (s *httpSink) encode(items []httpItem) []byte {
+	var buf bytes.Buffer
+
+	switch s.cfg.Format {
+	case LogHTTPFormat.ESBulk:
+		action, _ := json.Marshal(map[string]any{"index": map[string]string{"_index": s.cfg.Index}})
+		for _, item := range items {
+			buf.Write(action)
+			buf.WriteByte('\n')
+			buf.Write(item.log)
+			buf.WriteByte('\n')
+		}
+	case LogHTTPFormat.Loki:
+		values := make([][2]string, 0, len(items))
+		for _, item := range items {
+			values = append(values, [2]string{strconv.FormatInt(item.ts, 10), string(item.log)})
+		}
+		push := map[string]any{
+			"streams": []map[string]any{{"stream": s.cfg.Labels, "values": values}},
+		}
+		_ = json.NewEncoder(&buf).Encode(push)
+	case LogHTTPFormat.OTLPJSON:
+		return encodeOTLPJSON(otlpResource(s.cfg.Labels), buildOTLPScopes(items))
+	case LogHTTPFormat.OTLPProto:
+		return encodeOTLPProto(otlpResource(s.cfg.Labels), buildOTLPScopes(items))
+	default:
+		for _, item := range items {
+			buf.Write(item.log)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// send
+// @author Tianyi
+// @description 发送请求体，5xx、429 和网络错误按照指数退避重试，其他 4xx 不重试，
+//				关闭超时后停止重试，返回失败时是否可以重试
+func (s *httpSink) send(body []byte) (bool, error) {
+	var retry bool
+	var err error
+	backoff := s.cfg.RetryBackoff
+	for attempt := 0; attempt <= s.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-s.ctx.Done():
+				return retry, err
+			}
+			backoff *= 2
+		}
+
+		if retry, err = s.post(body); err == nil || !retry {
+			return retry, err
+		}
+	}
+	return retry, err
+}
+
+// post
+// @author Tianyi
+// @description 发送一次请求，返回失败时是否可以重试
+func (s *httpSink) post(body []byte) (bool, error) {
+	var reader io.Reader = bytes.NewReader(body)
+	if s.cfg.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		reader = &buf
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.cfg.URL, reader)
+	if err != nil {
+		return false, err
+	}
+	switch s.cfg.Format {
+	case LogHTTPFormat.Loki, LogHTTPFormat.OTLPJSON:
+		req.Header.Set("Content-Type", "application/json")
+	case LogHTTPFormat.OTLPProto:
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	default:
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if s.cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("http sink: unexpected status %s", resp.Status)
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// spool
+// @author Tianyi
+// @description 将发送失败的请求体保存到 SpoolDir，ext 为 .spool 时之后会重新发送，
+//				为 .rejected 时只保存用于排查，没有设置 SpoolDir 时返回 errDropped
+func (s *httpSink) spool(body []byte, ext string) error {
+	if len(s.cfg.SpoolDir) == 0 {
+		return errDropped
+	}
+	name := fmt.Sprintf("http-%020d%s", time.Now().UnixNano(), ext)
+	return os.WriteFile(filepath.Join(s.cfg.SpoolDir, name), body, 0666)
+}
+
+// replay
+// @author Tianyi
+// @description 按照保存的先后顺序重新发送 SpoolDir 中的请求体，遇到可以重试的失败时停止，
+//				服务端拒绝的请求体重命名为 .rejected
+func (s *httpSink) replay() {
+	if len(s.cfg.SpoolDir) == 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.cfg.SpoolDir)
+	if err != nil {
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "http-") && strings.HasSuffix(entry.Name(), ".spool") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(s.cfg.SpoolDir, name)
+		body, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		retry, err := s.post(body)
+		if err != nil && retry {
+			return
+		}
+		if err != nil {
+			_ = os.Rename(path, strings.TrimSuffix(path, ".spool")+".rejected")
+			continue
+		}
+		_ = file_op.Remove(path)
+	}
+}
@@ -0,0 +1,236 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPSinkESBulk(t *testing.T) {
+	ass := assert.New(t)
+
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ass.Equal("gzip", r.Header.Get("Content-Encoding"))
+		ass.Equal("secret", r.Header.Get("Authorization"))
+		zr, err := gzip.NewReader(r.Body)
+		ass.Nil(err, err)
+		body, _ := io.ReadAll(zr)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.HTTP).
+		SetHTTP(&HTTPConfig{
+			URL:           server.URL + "/_bulk",
+			Format:        LogHTTPFormat.ESBulk,
+			Index:         "app-logs",
+			Headers:       map[string]string{"Authorization": "secret"},
+			BatchSize:     2,
+			FlushInterval: time.Hour,
+			Gzip:          true,
+		}).
+		BuildE()
+	ass.Nil(err, err)
+
+	l.Info("first")
+	l.Info("second")
+	l.Info("third")
+	l.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	ass.Len(bodies, 2, "应该按照 BatchSize 分批发送")
+
+	lines := strings.Split(strings.TrimSpace(bodies[0]), "\n")
+	ass.Len(lines, 4)
+	ass.Equal(`{"index":{"_index":"app-logs"}}`, lines[0])
+	ass.Contains(lines[1], `"message":"first"`)
+	ass.Contains(lines[3], `"message":"second"`)
+	ass.Contains(bodies[1], `"message":"third"`)
+}
+
+func TestHTTPSinkRetryAndSpool(t *testing.T) {
+	ass := assert.New(t)
+
+	var requests int32
+	var received []string
+	var mu sync.Mutex
+	down := int32(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	cfg := &HTTPConfig{
+		URL:           server.URL,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		SpoolDir:      t.TempDir(),
+	}
+
+	// 服务不可用时，重试后保存到 SpoolDir
+	s, err := newHTTPSink(cfg)
+	ass.Nil(err, err)
+	ass.Nil(s.write(buildLogEntry(&YiLogConfig{}, LogLevel.InfoLevel, "spooled"), []byte(`{"message":"spooled"}`)))
+	ass.Nil(s.close())
+	ass.EqualValues(3, atomic.LoadInt32(&requests), "应该重试 2 次")
+
+	files, err := os.ReadDir(cfg.SpoolDir)
+	ass.Nil(err, err)
+	ass.Len(files, 1, "发送失败的日志应该保存到 SpoolDir")
+
+	// 服务恢复后，先发送保存的日志
+	atomic.StoreInt32(&down, 0)
+	s, err = newHTTPSink(cfg)
+	ass.Nil(err, err)
+	ass.Nil(s.write(buildLogEntry(&YiLogConfig{}, LogLevel.InfoLevel, "fresh"), []byte(`{"message":"fresh"}`)))
+	ass.Nil(s.close())
+
+	mu.Lock()
+	ass.Equal([]string{"{\"message\":\"spooled\"}\n", "{\"message\":\"fresh\"}\n"}, received)
+	mu.Unlock()
+
+	files, err = os.ReadDir(cfg.SpoolDir)
+	ass.Nil(err, err)
+	ass.Len(files, 0, "重新发送成功后应该删除")
+}
+
+func TestHTTPSinkMaxBuffer(t *testing.T) {
+	ass := assert.New(t)
+
+	// 不启动发送协程，模拟接口太慢
+	s := &httpSink{
+		asyncQueue: newAsyncQueue("", 3, false, func(_ *yiLogEntry, log []byte) []byte { return log }),
+		cfg:        HTTPConfig{BatchSize: 100, MaxBuffer: 3},
+	}
+	for _, msg := range []string{"1", "2", "3", "4", "5"} {
+		ass.Nil(s.write(buildLogEntry(&YiLogConfig{}, LogLevel.InfoLevel, msg), []byte(msg)))
+	}
+	ass.Len(s.items, 3, "缓存的日志不应该超过 MaxBuffer")
+	ass.Equal("3", string(s.items[0].data), "应该丢弃最早的日志")
+	ass.Equal(2, s.dropped, "丢弃的日志条数错误")
+}
+
+func TestHTTPSinkRejected(t *testing.T) {
+	ass := assert.New(t)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	cfg := &HTTPConfig{
+		URL:           server.URL,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+		SpoolDir:      t.TempDir(),
+	}
+
+	// 4xx 不重试，请求体保存为 .rejected，不会重新发送
+	s, err := newHTTPSink(cfg)
+	ass.Nil(err, err)
+	ass.Nil(s.write(buildLogEntry(&YiLogConfig{}, LogLevel.InfoLevel, "bad"), []byte(`{"message":"bad"}`)))
+	ass.Nil(s.close())
+	ass.EqualValues(1, atomic.LoadInt32(&requests), "4xx 不应该重试")
+
+	files, err := os.ReadDir(cfg.SpoolDir)
+	ass.Nil(err, err)
+	ass.Len(files, 1)
+	ass.True(strings.HasSuffix(files[0].Name(), ".rejected"), "被拒绝的请求体应该保存为 .rejected")
+
+	// 重新发送时被拒绝的请求体也不应该删除
+	spooled := filepath.Join(cfg.SpoolDir, "http-00000000000000000001.spool")
+	ass.Nil(os.WriteFile(spooled, []byte(`{"message":"old"}`+"\n"), 0666))
+	s, err = newHTTPSink(cfg)
+	ass.Nil(err, err)
+	ass.Nil(s.close())
+	ass.EqualValues(2, atomic.LoadInt32(&requests))
+	_, err = os.Stat(spooled)
+	ass.True(os.IsNotExist(err), "被拒绝的请求体不应该再次发送")
+	_, err = os.Stat(strings.TrimSuffix(spooled, ".spool") + ".rejected")
+	ass.Nil(err, "被拒绝的请求体应该重命名为 .rejected")
+}
+
+func TestHTTPSinkCloseTimeout(t *testing.T) {
+	ass := assert.New(t)
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cfg := &HTTPConfig{
+		URL:           server.URL,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		MaxRetries:    10,
+		RetryBackoff:  time.Second,
+		CloseTimeout:  100 * time.Millisecond,
+		SpoolDir:      t.TempDir(),
+	}
+	s, err := newHTTPSink(cfg)
+	ass.Nil(err, err)
+	for _, msg := range []string{"1", "2", "3"} {
+		ass.Nil(s.write(buildLogEntry(&YiLogConfig{}, LogLevel.InfoLevel, msg), []byte(msg)))
+	}
+
+	// 接口没有响应时，关闭不应该等待重试
+	start := time.Now()
+	ass.Nil(s.close())
+	ass.Less(time.Since(start), 2*time.Second, "关闭超时后应该停止发送")
+
+	files, err := os.ReadDir(cfg.SpoolDir)
+	ass.Nil(err, err)
+	ass.Len(files, 3, "没有发送的日志应该保存到 SpoolDir")
+}
+
+func TestHTTPSinkLoki(t *testing.T) {
+	ass := assert.New(t)
+
+	s := &httpSink{cfg: HTTPConfig{Format: LogHTTPFormat.Loki, Labels: map[string]string{"app": "api"}}}
+	body := s.encode([]httpItem{{ts: 1655194289000000000, log: []byte(`{"message":"hello"}`)}})
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	ass.Nil(json.NewDecoder(bufio.NewReader(strings.NewReader(string(body)))).Decode(&push))
+	ass.Len(push.Streams, 1)
+	ass.Equal("api", push.Streams[0].Stream["app"])
+	ass.Equal([][2]string{{"1655194289000000000", `{"message":"hello"}`}}, push.Streams[0].Values)
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"bytes"
 	"fmt"
 	"runtime"
 	"strings"
@@ -21,11 +22,50 @@ func getTraceAndLine() (string, int) {
 // formatMsg
 // @author Tianyi
 // @description 格式化日志信息，因为该日志框架使用的是 Json 保存，保证每行日志都是
-//				一个 Json 字符串，如果存在 '\n' 和 '\r'，就会导致 Json 字符串换
-//				行，无法统一数据格式，以后也不方便扩展日志框架功能
-func formatMsg(format string, a ...any) string {
+//				一个 Json 字符串，序列化时会对 '\n' 和 '\r' 等控制字符进行转义，
+//				所以默认将换行替换为空格只是为了兼容以前的输出，开启 keepNewline
+//				后会保留 SQL、调用栈等多行内容，非法的 UTF-8 字符会被替换为 U+FFFD
+func formatMsg(keepNewline bool, format string, a ...any) string {
 	msg := fmt.Sprintf(format, a...)
-	msg = strings.Replace(msg, "\n", " ", -1)
-	msg = strings.Replace(msg, "\r", " ", -1)
-	return msg
+	if !keepNewline {
+		msg = strings.Replace(msg, "\n", " ", -1)
+		msg = strings.Replace(msg, "\r", " ", -1)
+	}
+	return strings.ToValidUTF8(msg, "\uFFFD")
+}
+
+// appendJSONString
+// @author Tianyi
+// @description 将字符串转义为 Json 字符串，除了标准的转义外，还会转义 DEL、C1 控制字符
+//				以及 U+2028 和 U+2029，保证无论什么内容都只会输出一行合法的 Json，
+//				非法的 UTF-8 字符会被替换为 U+FFFD
+func appendJSONString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < 0x20 || (r >= 0x7f && r <= 0x9f):
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[r>>4])
+			buf.WriteByte(hex[r&0xf])
+		case r == '\u2028':
+			buf.WriteString(`\u2028`)
+		case r == '\u2029':
+			buf.WriteString(`\u2029`)
+		default:
+			// range 遍历时非法的 UTF-8 字符会被解析为 utf8.RuneError (U+FFFD)
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
 }
@@ -1,13 +1,20 @@
 package logger
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Chentyit/yi-logger/file_op"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Level 日志等级
@@ -63,6 +70,49 @@ var LogTimeFormat = struct {
 	"15:04:05",
 }
 
+// TimeEncoder 时间编码方式
+type TimeEncoder byte
+
+// LogTimeEncoder 日志时间编码方式
+var LogTimeEncoder = struct {
+	Layout      TimeEncoder
+	RFC3339Nano TimeEncoder
+	UnixSecond  TimeEncoder
+	UnixMilli   TimeEncoder
+	UnixNano    TimeEncoder
+	Default     TimeEncoder
+}{0, 1, 2, 3, 4, 0}
+
+// Clock 时钟接口，测试时可以使用 FakeClock 控制时间
+type Clock = file_op.Clock
+
+// FakeClock 可以手动控制的时钟
+type FakeClock = file_op.FakeClock
+
+// NewFakeClock
+// @author Tianyi
+// @description 创建一个可以手动控制的时钟
+func NewFakeClock(now time.Time) *FakeClock {
+	return file_op.NewFakeClock(now)
+}
+
+// FileLayout 日志文件布局
+type FileLayout = file_op.Layout
+
+// LogFileLayout 日志文件布局选项: Rename (默认)、Symlink、Truncate
+var LogFileLayout = file_op.Layouts
+
+// FileRepair 打开已有的日志文件时修复末尾不完整的行的方式
+type FileRepair byte
+
+// LogFileRepair 修复方式选项
+var LogFileRepair = struct {
+	Move    FileRepair // 没有换行结尾或者不是合法 Json 的最后一行移动到 "文件名.corrupt"
+	Newline FileRepair // 只在没有换行结尾的最后一行末尾补上换行
+	Off     FileRepair // 不修复
+	Default FileRepair
+}{0, 1, 2, 0}
+
 // OutPutWay 输出方式
 type OutPutWay byte
 
@@ -70,52 +120,144 @@ type OutPutWay byte
 var OutPut = struct {
 	Console OutPutWay
 	File    OutPutWay
+	Syslog  OutPutWay
+	HTTP    OutPutWay
+	Network OutPutWay
+	Fluent  OutPutWay
 	Default OutPutWay
-}{0, 1, 0}
+}{0, 1, 2, 3, 4, 5, 0}
 
 // YiLogConfig
 // @author Tianyi
 // @description 日志基础配置
 type YiLogConfig struct {
-	Compress   bool       // 是否需要压缩日志文件
-	LogLevel   Level      // 日志等级 (默认: TraceLevel -> 0 打印所有类型日志)
-	MaxSize    int        // 每个日志最大容量 (默认: 10，单位: MB)
-	MaxBackups int        // 最多保存记录个数 (默认：5)
-	MaxAge     int        // 做多保存天数	(默认: 7)
-	OutputWay  OutPutWay  // 输出方式 (默认: 0 -> 输出到控制台)
-	DateFormat DateFormat // 日期格式 (默认: yyyy-MM-dd)
-	TimeFormat TimeFormat // 时间格式 (默认: hh:HH:ss)
-	File       string     // 日志保存文件 (默认: ./当前目录)
+	Compress    bool       // 是否需要压缩日志文件
+	LogLevel    Level      // 日志等级 (默认: TraceLevel -> 0 打印所有类型日志)
+	MaxSize     int        // 每个日志最大容量 (默认: 10，单位: MB)
+	MaxLines    int        // 每个日志最多的行数，和 MaxSize 任意一个达到即切分 (默认: 0 -> 不限制)
+	MaxBackups  int        // 最多保存记录个数，小于 0 时不限制 (默认：5)
+	MaxAge      int        // 做多保存天数，小于 0 时不限制	(默认: 7)
+	RotateDaily bool       // 是否按日期切分，跨天后第一次写入前切分 (默认: false -> 只按照 MaxSize 和 MaxLines 切分)
+	OutputWay   OutPutWay  // 输出方式 (默认: 0 -> 输出到控制台)
+	DateFormat  DateFormat // 日期格式 (默认: yyyy-MM-dd)
+	TimeFormat  TimeFormat // 时间格式 (默认: HH:mm:ss)
+	File        string     // 日志保存文件，支持 %Y %m %d %H %M、{date}、{service} 路径模板 (默认: ./当前目录)
+	Service     string     // 服务名称，用于路径模板中的 {service} (默认: 程序名称)
+
+	ReopenOnSIGHUP    bool          // 收到 SIGHUP 信号时重新打开日志文件，配合 logrotate 使用 (默认: false)
+	FileCheckInterval time.Duration // 检查日志文件是否被移动或者删除的间隔 (默认: 1s，小于 0 时不检查)
+	FileLayout        FileLayout    // 日志文件布局 (默认: Rename -> 切分时改名为历史日志)
+	FileRepair        FileRepair    // 打开已有的日志文件时修复末尾不完整的行 (默认: Move -> 移动到 .corrupt 文件)
+
+	MaxTotalSize   int    // 主日志、路由规则写入的日志以及它们的历史日志的总容量，超过后从最早的历史日志开始删除 (默认: 0 -> 不限制，单位: MB)
+	MinFreeSpace   int    // 日志目录所在磁盘的最小可用空间，低于该值时丢弃 FreeSpaceLevel 以下的日志 (默认: 0 -> 不检查，单位: MB)
+	FreeSpaceLevel *Level // 磁盘可用空间不足时仍然写入的最低日志级别 (默认: nil -> ErrorLevel)
+
+	Routes       []Route // 路由规则，满足条件的日志额外写入单独的文件，不支持控制台输出
+	MaxOpenFiles int     // 按照字段值路由时最多同时打开的文件数 (默认: 64)
+
+	TimeEncoder TimeEncoder    // 时间编码方式 (默认: Layout -> 使用 DateFormat 和 TimeFormat 格式化)
+	TimeLayout  string         // 自定义时间格式，设置后代替 DateFormat 和 TimeFormat
+	TimeZone    *time.Location // 时区 (默认: 本地时区)
+	EnableTs    bool           // 是否额外输出 ts 字段 (unix 纳秒)，用于机器排序 (默认: false)
+
+	Clock Clock // 时钟，用于生成日志时间和切分日志文件 (默认: 系统时钟)
+
+	Schema *EntrySchema // 日志 Json 字段名称映射 (默认: LogSchema.Default)
+
+	KeepNewline bool // 是否保留日志信息中的换行，不保留时替换为空格 (默认: false)
+
+	Console ConsoleFormat // 控制台输出格式 (默认: JSON)
+
+	Dedup       bool          // 是否合并连续重复的日志 (默认: false)
+	DedupWindow time.Duration // 合并重复日志的时间窗口 (默认: 1s)
+
+	Sync         SyncPolicy      // 日志文件同步到磁盘 (fsync) 的策略 (默认: Never -> 由操作系统决定)
+	SyncInterval time.Duration   // Interval 策略的同步间隔 (默认: 1s)
+	SyncEntries  int             // Entries 策略下每多少条日志同步一次 (默认: 100)
+	SyncLevel    *Level          // Level 策略下需要同步的最低日志级别 (默认: nil -> ErrorLevel)
+	SyncWait     bool            // 同步模式，Level 和 Always 策略下需要同步的日志写入磁盘后才返回，只支持文件输出 (默认: false)
+	OnSyncError  func(err error) // 同步模式下日志没有写入磁盘时调用，在等待的日志返回之前执行 (默认: nil)
+
+	Syslog  *SyslogConfig  // syslog 输出配置，OutputWay 为 Syslog 时使用
+	HTTP    *HTTPConfig    // HTTP 输出配置，OutputWay 为 HTTP 时使用
+	Network *NetworkConfig // TCP/UDP 输出配置，OutputWay 为 Network 时使用
+	Fluent  *FluentConfig  // Fluentd Forward 协议输出配置，OutputWay 为 Fluent 时使用
+
+	Spool *SpoolConfig // 预写队列配置，用于需要至少一次投递的日志 (默认: nil -> 只使用内存通道)
+
+	SpanExtractor SpanExtractor // 从 context 中获取 trace_id 和 span_id (默认: 读取 ContextWithSpan 保存的信息)
 }
 
 // yiLogEntry
 // @author Tianyi
 // @description 每行日志记录
 type yiLogEntry struct {
-	DateTime string `json:"time"`    // 日志记录时间
-	Trace    string `json:"trace"`   // 文件路径
-	Line     int    `json:"line"`    // 文件行数
-	Level    string `json:"level"`   // 日志级别
-	Message  string `json:"message"` // 日志信息
+	DateTime any    `json:"time"`             // 日志记录时间
+	Ts       int64  `json:"ts,omitempty"`     // 日志记录时间 (unix 纳秒)
+	Trace    string `json:"trace"`            // 文件路径
+	Line     int    `json:"line"`             // 文件行数
+	Level    string `json:"level"`            // 日志级别
+	Name     string `json:"logger,omitempty"` // logger 名称
+	Message  string `json:"message"`          // 日志信息
+
+	TraceID string `json:"trace_id,omitempty"` // 链路追踪 trace_id
+	SpanID  string `json:"span_id,omitempty"`  // 链路追踪 span_id
+
+	Fields []Field `json:"fields,omitempty"` // 结构化字段
+
+	Repeated  int `json:"repeated,omitempty"`   // 连续重复次数
+	FirstTime any `json:"first_time,omitempty"` // 重复日志首次出现时间
+	LastTime  any `json:"last_time,omitempty"`  // 重复日志最后出现时间
+
+	lvl  Level            // 日志等级
+	at   time.Time        // 日志生成时间，用于判断重复日志是否超过合并窗口
+	done chan struct{}    // 同步模式下写入磁盘后关闭
+	pos  file_op.SpoolPos // 在预写队列中的位置，零值表示不是从预写队列中读取的
+}
+
+// Field
+// @author Tianyi
+// @description 日志结构化字段，序列化时作为日志 Json 的一个字段输出
+type Field struct {
+	Key   string
+	Value any
 }
 
 // yiLogger
 // @author Tianyi
 // @description 通过 yiLogger 进行操作（写，读，创建文件等）
 type yiLogger struct {
-	statue   bool            // logger 状态，false 关闭，true 打开
+	statue   *atomic.Bool    // logger 状态，false 关闭，true 打开，Named 创建的子 Logger 共享状态
+	name     string          // logger 名称
 	mu       *sync.Mutex     // 同步锁
 	fo       *file_op.FileOp // 文件 IO
+	sink     sink            // 日志输出目标 (文件、syslog 等)
+	console  *consoleWriter  // 控制台输出
+	spool    *file_op.Spool  // 预写队列
+	spoolAck *spoolAck       // 预写队列中日志的确认状态
+	guard    *diskGuard      // 磁盘可用空间保护
+	diag     []*yiLogEntry   // 等待写入的诊断日志，例如修复日志文件之后的提示
+	durable  *durability     // 同步到磁盘的策略
 	date     time.Time       // 日期，用于判断是否需要换文件
 	cfg      *YiLogConfig    // logger config
 	exitChan chan struct{}   // 用于关闭 Logger
-	logCh    chan []byte
+	doneChan chan struct{}   // writer 退出后关闭，用于等待日志全部写完
+	reopenCh chan chan error // 通知 writer 重新打开输出目标
+	logCh    chan *yiLogEntry
 }
 
 // BuildLogger
 // @author Tianyi
-// @description 时间传参进行配置
+// @description 时间传参进行配置，配置有误时输出错误信息并使用默认配置，需要处理错误时使用 New
 func BuildLogger(cfg *YiLogConfig) *yiLogger {
+	return mustBuildLogger(cfg)
+}
+
+// New
+// @author Tianyi
+// @description 根据配置构建 Logger，配置有误时返回 error
+func New(cfg *YiLogConfig) (*yiLogger, error) {
 	return buildLogger(cfg)
 }
 
@@ -182,6 +324,14 @@ func (cfg *YiLogConfig) SetMaxAge(maxAge int) *YiLogConfig {
 	return cfg
 }
 
+// SetRotateDaily
+// @author Tianyi
+// @description 设置是否按日期切分
+func (cfg *YiLogConfig) SetRotateDaily(daily bool) *YiLogConfig {
+	cfg.RotateDaily = daily
+	return cfg
+}
+
 // SetFile
 // @author Tianyi
 // @description 设置保存日志文件
@@ -198,10 +348,274 @@ func (cfg *YiLogConfig) SetOutput(outputWay OutPutWay) *YiLogConfig {
 	return cfg
 }
 
+// SetDedup
+// @author Tianyi
+// @description 设置是否合并连续重复的日志
+func (cfg *YiLogConfig) SetDedup(dedup bool) *YiLogConfig {
+	cfg.Dedup = dedup
+	return cfg
+}
+
+// SetDedupWindow
+// @author Tianyi
+// @description 设置合并重复日志的时间窗口
+func (cfg *YiLogConfig) SetDedupWindow(window time.Duration) *YiLogConfig {
+	cfg.DedupWindow = window
+	return cfg
+}
+
+// SetTimeEncoder
+// @author Tianyi
+// @description 设置时间编码方式
+func (cfg *YiLogConfig) SetTimeEncoder(te TimeEncoder) *YiLogConfig {
+	cfg.TimeEncoder = te
+	return cfg
+}
+
+// SetTimeLayout
+// @author Tianyi
+// @description 设置自定义时间格式
+func (cfg *YiLogConfig) SetTimeLayout(layout string) *YiLogConfig {
+	cfg.TimeLayout = layout
+	return cfg
+}
+
+// SetTimeZone
+// @author Tianyi
+// @description 设置时区
+func (cfg *YiLogConfig) SetTimeZone(loc *time.Location) *YiLogConfig {
+	cfg.TimeZone = loc
+	return cfg
+}
+
+// SetEnableTs
+// @author Tianyi
+// @description 设置是否输出 ts 字段
+func (cfg *YiLogConfig) SetEnableTs(enable bool) *YiLogConfig {
+	cfg.EnableTs = enable
+	return cfg
+}
+
+// SetClock
+// @author Tianyi
+// @description 设置时钟
+func (cfg *YiLogConfig) SetClock(clock Clock) *YiLogConfig {
+	cfg.Clock = clock
+	return cfg
+}
+
+// SetSchema
+// @author Tianyi
+// @description 设置日志 Json 字段名称映射
+func (cfg *YiLogConfig) SetSchema(schema EntrySchema) *YiLogConfig {
+	cfg.Schema = &schema
+	return cfg
+}
+
+// SetKeepNewline
+// @author Tianyi
+// @description 设置是否保留日志信息中的换行
+func (cfg *YiLogConfig) SetKeepNewline(keep bool) *YiLogConfig {
+	cfg.KeepNewline = keep
+	return cfg
+}
+
+// SetSyslog
+// @author Tianyi
+// @description 设置 syslog 输出配置
+func (cfg *YiLogConfig) SetSyslog(syslog *SyslogConfig) *YiLogConfig {
+	cfg.Syslog = syslog
+	return cfg
+}
+
+// SetHTTP
+// @author Tianyi
+// @description 设置 HTTP 输出配置
+func (cfg *YiLogConfig) SetHTTP(http *HTTPConfig) *YiLogConfig {
+	cfg.HTTP = http
+	return cfg
+}
+
+// SetSpool
+// @author Tianyi
+// @description 设置预写队列配置
+func (cfg *YiLogConfig) SetSpool(spool *SpoolConfig) *YiLogConfig {
+	cfg.Spool = spool
+	return cfg
+}
+
+// SetNetwork
+// @author Tianyi
+// @description 设置 TCP/UDP 输出配置
+func (cfg *YiLogConfig) SetNetwork(network *NetworkConfig) *YiLogConfig {
+	cfg.Network = network
+	return cfg
+}
+
+// SetFluent
+// @author Tianyi
+// @description 设置 Fluentd Forward 协议输出配置
+func (cfg *YiLogConfig) SetFluent(fluent *FluentConfig) *YiLogConfig {
+	cfg.Fluent = fluent
+	return cfg
+}
+
+// SetService
+// @author Tianyi
+// @description 设置服务名称
+func (cfg *YiLogConfig) SetService(service string) *YiLogConfig {
+	cfg.Service = service
+	return cfg
+}
+
+// SetReopenOnSIGHUP
+// @author Tianyi
+// @description 设置收到 SIGHUP 信号时重新打开日志文件
+func (cfg *YiLogConfig) SetReopenOnSIGHUP(reopen bool) *YiLogConfig {
+	cfg.ReopenOnSIGHUP = reopen
+	return cfg
+}
+
+// SetFileCheckInterval
+// @author Tianyi
+// @description 设置检查日志文件是否被移动或者删除的间隔，小于 0 时不检查
+func (cfg *YiLogConfig) SetFileCheckInterval(interval time.Duration) *YiLogConfig {
+	cfg.FileCheckInterval = interval
+	return cfg
+}
+
+// SetFileLayout
+// @author Tianyi
+// @description 设置日志文件布局
+func (cfg *YiLogConfig) SetFileLayout(layout FileLayout) *YiLogConfig {
+	cfg.FileLayout = layout
+	return cfg
+}
+
+// SetFileRepair
+// @author Tianyi
+// @description 设置打开已有的日志文件时修复末尾不完整的行的方式
+func (cfg *YiLogConfig) SetFileRepair(repair FileRepair) *YiLogConfig {
+	cfg.FileRepair = repair
+	return cfg
+}
+
+// SetMaxLines
+// @author Tianyi
+// @description 设置每个日志最多的行数
+func (cfg *YiLogConfig) SetMaxLines(maxLines int) *YiLogConfig {
+	cfg.MaxLines = maxLines
+	return cfg
+}
+
+// SetMaxTotalSize
+// @author Tianyi
+// @description 设置当前日志和历史日志的总容量 (MB)
+func (cfg *YiLogConfig) SetMaxTotalSize(maxTotalSize int) *YiLogConfig {
+	cfg.MaxTotalSize = maxTotalSize
+	return cfg
+}
+
+// SetMinFreeSpace
+// @author Tianyi
+// @description 设置磁盘最小可用空间 (MB)，低于该值时只写入 level 及以上的日志
+func (cfg *YiLogConfig) SetMinFreeSpace(minFreeSpace int, level Level) *YiLogConfig {
+	cfg.MinFreeSpace = minFreeSpace
+	cfg.FreeSpaceLevel = &level
+	return cfg
+}
+
+// SetSync
+// @author Tianyi
+// @description 设置日志文件同步到磁盘的策略
+func (cfg *YiLogConfig) SetSync(policy SyncPolicy) *YiLogConfig {
+	cfg.Sync = policy
+	return cfg
+}
+
+// SetSyncInterval
+// @author Tianyi
+// @description 设置 Interval 策略的同步间隔
+func (cfg *YiLogConfig) SetSyncInterval(interval time.Duration) *YiLogConfig {
+	cfg.SyncInterval = interval
+	return cfg
+}
+
+// SetSyncEntries
+// @author Tianyi
+// @description 设置 Entries 策略下每多少条日志同步一次
+func (cfg *YiLogConfig) SetSyncEntries(entries int) *YiLogConfig {
+	cfg.SyncEntries = entries
+	return cfg
+}
+
+// SetSyncLevel
+// @author Tianyi
+// @description 设置 Level 策略下需要同步的最低日志级别
+func (cfg *YiLogConfig) SetSyncLevel(level Level) *YiLogConfig {
+	cfg.SyncLevel = &level
+	return cfg
+}
+
+// SetSyncWait
+// @author Tianyi
+// @description 设置同步模式，需要同步的日志写入磁盘后才返回，例如审计日志
+func (cfg *YiLogConfig) SetSyncWait(wait bool) *YiLogConfig {
+	cfg.SyncWait = wait
+	return cfg
+}
+
+// SetOnSyncError
+// @author Tianyi
+// @description 设置同步模式下日志写入失败、同步失败或者因为磁盘可用空间不足被丢弃时的回调
+func (cfg *YiLogConfig) SetOnSyncError(onError func(err error)) *YiLogConfig {
+	cfg.OnSyncError = onError
+	return cfg
+}
+
+// SetRoutes
+// @author Tianyi
+// @description 设置路由规则
+func (cfg *YiLogConfig) SetRoutes(routes ...Route) *YiLogConfig {
+	cfg.Routes = routes
+	return cfg
+}
+
+// SetMaxOpenFiles
+// @author Tianyi
+// @description 设置按照字段值路由时最多同时打开的文件数
+func (cfg *YiLogConfig) SetMaxOpenFiles(maxOpenFiles int) *YiLogConfig {
+	cfg.MaxOpenFiles = maxOpenFiles
+	return cfg
+}
+
+// SetConsole
+// @author Tianyi
+// @description 设置控制台输出格式
+func (cfg *YiLogConfig) SetConsole(format ConsoleFormat) *YiLogConfig {
+	cfg.Console = format
+	return cfg
+}
+
+// SetSpanExtractor
+// @author Tianyi
+// @description 设置从 context 中获取 trace_id 和 span_id 的方法
+func (cfg *YiLogConfig) SetSpanExtractor(extractor SpanExtractor) *YiLogConfig {
+	cfg.SpanExtractor = extractor
+	return cfg
+}
+
 // Build
 // @author Tianyi
-// @description
+// @description 根据配置构建 Logger，配置有误时输出错误信息并使用默认配置，需要处理错误时使用 BuildE
 func (cfg *YiLogConfig) Build() *yiLogger {
+	return mustBuildLogger(cfg)
+}
+
+// BuildE
+// @author Tianyi
+// @description 根据配置构建 Logger，配置有误时返回 error
+func (cfg *YiLogConfig) BuildE() (*yiLogger, error) {
 	return buildLogger(cfg)
 }
 
@@ -209,25 +623,169 @@ func (cfg *YiLogConfig) Build() *yiLogger {
 // @author Tianyi
 // @description 构建每行日志记录
 func buildLogEntry(cfg *YiLogConfig, level Level, msg string) *yiLogEntry {
-
-	parser := fmt.Sprintf("%v %v", cfg.DateFormat, cfg.TimeFormat)
-	dateTime := time.Now().Format(parser)
 	// 定位调用目标
 	trace, line := getTraceAndLine()
 
-	return &yiLogEntry{
-		DateTime: dateTime,
+	return newLogEntry(cfg, level, msg, cfg.now(), trace, line)
+}
+
+// newLogEntry
+// @author Tianyi
+// @description 使用指定的时间和调用位置构建日志记录，用于 slog 等适配器
+func newLogEntry(cfg *YiLogConfig, level Level, msg string, now time.Time, trace string, line int) *yiLogEntry {
+	entry := &yiLogEntry{
+		DateTime: encodeTime(cfg, now),
 		Trace:    trace,
 		Line:     line,
 		Level:    logLevel[level],
 		Message:  msg,
+		lvl:      level,
+		at:       now,
+	}
+	if cfg.EnableTs {
+		entry.Ts = now.UnixNano()
+	}
+	return entry
+}
+
+// now
+// @author Tianyi
+// @description 通过配置的时钟获取当前时间
+func (cfg *YiLogConfig) now() time.Time {
+	if cfg.Clock == nil {
+		return time.Now()
+	}
+	return cfg.Clock.Now()
+}
+
+// encodeTime
+// @author Tianyi
+// @description 根据配置的时区和编码方式编码日志时间
+func encodeTime(cfg *YiLogConfig, t time.Time) any {
+	if cfg.TimeZone != nil {
+		t = t.In(cfg.TimeZone)
+	}
+
+	switch cfg.TimeEncoder {
+	case LogTimeEncoder.RFC3339Nano:
+		return t.Format(time.RFC3339Nano)
+	case LogTimeEncoder.UnixSecond:
+		return t.Unix()
+	case LogTimeEncoder.UnixMilli:
+		return t.UnixMilli()
+	case LogTimeEncoder.UnixNano:
+		return t.UnixNano()
+	default:
+		return t.Format(timeLayout(cfg))
+	}
+}
+
+// timeLayout
+// @author Tianyi
+// @description 获取时间格式，优先使用自定义格式
+func timeLayout(cfg *YiLogConfig) string {
+	if len(cfg.TimeLayout) != 0 {
+		return cfg.TimeLayout
 	}
+	return fmt.Sprintf("%v %v", cfg.DateFormat, cfg.TimeFormat)
+}
+
+// errInvalidLayout 时间格式不合法
+var errInvalidLayout = errors.New("invalid time layout")
+
+// layoutElements Go layout 中的时间元素，同一位置优先匹配较长的元素
+var layoutElements = []string{
+	"January", "Monday", "Z07:00:00", "-07:00:00", "Z070000", "-070000", "Z07:00", "-07:00", "Z0700", "-0700",
+	"2006", "Jan", "Mon", "MST", "002", "__2", "Z07", "-07",
+	"_2", "01", "02", "03", "04", "05", "06", "15", "PM", "pm",
+	"1", "2", "3", "4", "5",
+}
+
+// validateLayout
+// @author Tianyi
+// @description 校验时间格式是否是合法的 Go layout，layout 只能由时间元素和分隔符组成，
+//				格式化后的结果必须和 layout 不同 (至少包含一个时间元素)，并且能够被重新解析
+func validateLayout(layout string) error {
+	if garbage, ok := layoutGarbage(layout); ok {
+		return fmt.Errorf("%w %q: unknown element %q", errInvalidLayout, layout, garbage)
+	}
+	ref := time.Date(2022, 6, 14, 16, 11, 29, 0, time.UTC)
+	formatted := ref.Format(layout)
+	if formatted == layout {
+		return fmt.Errorf("%w %q: no time elements", errInvalidLayout, layout)
+	}
+	if _, err := time.Parse(layout, formatted); err != nil {
+		return fmt.Errorf("%w %q: %v", errInvalidLayout, layout, err)
+	}
+	return nil
+}
+
+// layoutGarbage
+// @author Tianyi
+// @description 查找 layout 中既不是时间元素也不是分隔符的内容，字母和数字只能出现在时间元素中，
+//				'T' 和 'Z' 除外 (例如 RFC 3339 中的 "T" 和 UTC 时间结尾的 "Z")
+func layoutGarbage(layout string) (string, bool) {
+	for i := 0; i < len(layout); {
+		// 小数秒: .000、.999、,000
+		if c := layout[i]; (c == '.' || c == ',') && i+1 < len(layout) && (layout[i+1] == '0' || layout[i+1] == '9') {
+			j := i + 1
+			for j < len(layout) && layout[j] == layout[i+1] {
+				j++
+			}
+			i = j
+			continue
+		}
+
+		matched := false
+		for _, element := range layoutElements {
+			if strings.HasPrefix(layout[i:], element) {
+				i += len(element)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(layout[i:])
+		if r != 'T' && r != 'Z' && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return layout[i:], true
+		}
+		i += size
+	}
+	return "", false
+}
+
+// mustBuildLogger
+// @author Tianyi
+// @description 构建 Logger 对象，配置有误时输出错误信息到标准错误，时间格式不合法时使用默认格式，
+//				其他错误使用默认配置输出到控制台，保证调用方总能拿到可用的 Logger
+func mustBuildLogger(cfg *YiLogConfig) *yiLogger {
+	logger, err := buildLogger(cfg)
+	if err == nil {
+		return logger
+	}
+
+	if errors.Is(err, errInvalidLayout) {
+		fmt.Fprintf(os.Stderr, "yi-logger: %v, using the default time layout\n", err)
+		cfg.TimeLayout = ""
+		cfg.DateFormat = LogDateFormat.Default
+		cfg.TimeFormat = LogTimeFormat.Default
+		if logger, err = buildLogger(cfg); err == nil {
+			return logger
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "yi-logger: %v, falling back to console output\n", err)
+	logger, _ = buildLogger(&YiLogConfig{LogLevel: cfg.LogLevel, Clock: cfg.Clock})
+	return logger
 }
 
 // buildLogger
 // @author Tianyi
 // @description 构建 Logger 对象
-func buildLogger(cfg *YiLogConfig) *yiLogger {
+func buildLogger(cfg *YiLogConfig) (*yiLogger, error) {
 	// 配置默认值
 	if cfg.MaxSize == 0 {
 		cfg.MaxSize = 10
@@ -242,47 +800,173 @@ func buildLogger(cfg *YiLogConfig) *yiLogger {
 	}
 
 	if len(cfg.DateFormat) == 0 {
-		cfg.DateFormat = "yyyy/MM/dd"
+		cfg.DateFormat = LogDateFormat.Default
 	}
 
 	if len(cfg.TimeFormat) == 0 {
-		cfg.TimeFormat = "hh:HH:ss"
+		cfg.TimeFormat = LogTimeFormat.Default
+	}
+
+	if cfg.TimeEncoder > LogTimeEncoder.UnixNano {
+		return nil, fmt.Errorf("invalid time encoder: %d", cfg.TimeEncoder)
+	}
+
+	if cfg.TimeEncoder == LogTimeEncoder.Layout {
+		if err := validateLayout(timeLayout(cfg)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Clock == nil {
+		cfg.Clock = file_op.SystemClock
+	}
+
+	if cfg.Schema == nil {
+		schema := LogSchema.Default
+		cfg.Schema = &schema
+	}
+
+	if cfg.Dedup && cfg.DedupWindow <= 0 {
+		cfg.DedupWindow = time.Second
+	}
+
+	if cfg.Sync > LogSync.Always {
+		return nil, fmt.Errorf("invalid sync policy: %d", cfg.Sync)
+	}
+
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = time.Second
+	}
+
+	if cfg.SyncEntries <= 0 {
+		cfg.SyncEntries = 100
+	}
+
+	if cfg.SyncLevel != nil && *cfg.SyncLevel > LogLevel.PanicLevel {
+		return nil, fmt.Errorf("invalid sync level: %d", *cfg.SyncLevel)
+	}
+
+	if cfg.Console > LogConsoleFormat.Pretty {
+		return nil, fmt.Errorf("invalid console format: %d", cfg.Console)
 	}
 
 	if cfg.OutputWay == OutPut.File && len(cfg.File) == 0 {
 		cfg.File = "./"
 	}
 
-	var logger *yiLogger
+	if len(cfg.Service) == 0 {
+		cfg.Service = filepath.Base(os.Args[0])
+	}
+
+	if cfg.FileLayout > LogFileLayout.Truncate {
+		return nil, fmt.Errorf("invalid file layout: %d", cfg.FileLayout)
+	}
+
+	if cfg.FileRepair > LogFileRepair.Off {
+		return nil, fmt.Errorf("invalid file repair: %d", cfg.FileRepair)
+	}
+
+	if cfg.FileCheckInterval == 0 {
+		cfg.FileCheckInterval = time.Second
+	}
+
+	if cfg.FreeSpaceLevel != nil && *cfg.FreeSpaceLevel > LogLevel.PanicLevel {
+		return nil, fmt.Errorf("invalid free space level: %d", *cfg.FreeSpaceLevel)
+	}
+
+	logger := &yiLogger{
+		date: cfg.now(),
+		cfg:  cfg,
+	}
 
 	if cfg.OutputWay == OutPut.Default || cfg.OutputWay == OutPut.Console {
-		logger = &yiLogger{
-			fo:   nil,
-			date: time.Now(),
-			cfg:  cfg,
+		if len(cfg.Routes) != 0 {
+			return nil, errors.New("routes are not supported for console output")
 		}
+		logger.console = newConsoleWriter(cfg)
 	} else {
-		fo := file_op.CreateFileOp(cfg.File, cfg.MaxSize, cfg.Compress)
-		logger = &yiLogger{
-			fo:   fo,
-			date: time.Now(),
-			cfg:  cfg,
+		if err := logger.buildSink(); err != nil {
+			return nil, err
+		}
+		if logger.fo != nil {
+			logger.guard = newDiskGuard(cfg, logger.fo.Path)
+		}
+		logger.durable = newDurability(cfg)
+		if logger.durable.waits() && !logger.canSync() {
+			_ = logger.sink.close()
+			return nil, errors.New("sync wait is only supported for file output")
+		}
+		if cfg.Spool != nil {
+			sp, err := openSpool(cfg.Spool)
+			if err != nil {
+				_ = logger.sink.close()
+				return nil, err
+			}
+			logger.spool = sp
+			logger.spoolAck = &spoolAck{}
+			if a, ok := logger.sink.(acker); ok {
+				logger.spoolAck.async = a.setAck(logger.spoolAck.ack)
+			}
 		}
 		// 初始化 Channel
-		logger.logCh = make(chan []byte, runtime.NumCPU())
+		logger.logCh = make(chan *yiLogEntry, runtime.NumCPU())
 		logger.exitChan = make(chan struct{})
+		logger.doneChan = make(chan struct{})
+		logger.reopenCh = make(chan chan error)
 		// 开启通道接收日志
 		go logger.writer()
 	}
 
-	logger.statue = true
+	logger.statue = &atomic.Bool{}
+	logger.statue.Store(true)
 
-	return logger
+	return logger, nil
 }
 
+// Named
+// @author Tianyi
+// @description 创建一个带有名称的子 Logger，和原 Logger 共享配置和输出，
+//				多次调用时名称使用 '.' 拼接，关闭任意一个都会关闭所有 Logger
+func (logger *yiLogger) Named(name string) *yiLogger {
+	child := *logger
+	if len(logger.name) != 0 && len(name) != 0 {
+		child.name = logger.name + "." + name
+	} else if len(name) != 0 {
+		child.name = name
+	}
+	return &child
+}
+
+// Reopen
+// @author Tianyi
+// @description 重新打开日志文件，用于 logrotate 等外部工具移动日志文件之后，
+//				在 writer 协程中完成，返回时之后的日志已经写入新的文件
+func (logger *yiLogger) Reopen() error {
+	if logger.reopenCh == nil {
+		return nil
+	}
+	ch := make(chan error, 1)
+	select {
+	case logger.reopenCh <- ch:
+		return <-ch
+	case <-logger.doneChan:
+		return errors.New("logger is closed")
+	}
+}
+
+// Close
+// @author Tianyi
+// @description 关闭 Logger，等待缓冲中的日志全部写完后返回
 func (logger *yiLogger) Close() {
-	logger.statue = false
-	close(logger.exitChan)
+	// Named 创建的子 Logger 共享状态，只有第一次关闭时通知 writer 退出
+	closing := logger.statue.CompareAndSwap(true, false)
+	if logger.exitChan == nil {
+		return
+	}
+	if closing {
+		close(logger.exitChan)
+	}
+	<-logger.doneChan
 }
 
 func (logger *yiLogger) Trace(format string, a ...any) {
@@ -290,9 +974,9 @@ func (logger *yiLogger) Trace(format string, a ...any) {
 		return
 	}
 
-	log := logger.makeLog(LogLevel.TraceLevel, format, a...)
+	entry := logger.makeLog(LogLevel.TraceLevel, format, a...)
 
-	logger.output(log)
+	logger.output(entry)
 }
 
 func (logger *yiLogger) Debug(format string, a ...any) {
@@ -300,9 +984,9 @@ func (logger *yiLogger) Debug(format string, a ...any) {
 		return
 	}
 
-	log := logger.makeLog(LogLevel.DebugLevel, format, a...)
+	entry := logger.makeLog(LogLevel.DebugLevel, format, a...)
 
-	logger.output(log)
+	logger.output(entry)
 }
 
 func (logger *yiLogger) Info(format string, a ...any) {
@@ -311,9 +995,9 @@ func (logger *yiLogger) Info(format string, a ...any) {
 		return
 	}
 
-	log := logger.makeLog(LogLevel.InfoLevel, format, a...)
+	entry := logger.makeLog(LogLevel.InfoLevel, format, a...)
 
-	logger.output(log)
+	logger.output(entry)
 }
 
 func (logger *yiLogger) Warn(format string, a ...any) {
@@ -321,9 +1005,9 @@ func (logger *yiLogger) Warn(format string, a ...any) {
 		return
 	}
 
-	log := logger.makeLog(LogLevel.WarnLevel, format, a...)
+	entry := logger.makeLog(LogLevel.WarnLevel, format, a...)
 
-	logger.output(log)
+	logger.output(entry)
 }
 
 func (logger *yiLogger) Error(format string, a ...any) {
@@ -331,9 +1015,9 @@ func (logger *yiLogger) Error(format string, a ...any) {
 		return
 	}
 
-	log := logger.makeLog(LogLevel.ErrorLevel, format, a...)
+	entry := logger.makeLog(LogLevel.ErrorLevel, format, a...)
 
-	logger.output(log)
+	logger.output(entry)
 }
 
 // Panic
@@ -344,51 +1028,264 @@ func (logger *yiLogger) Panic(format string, a ...any) {
 		return
 	}
 
-	log := logger.makeLog(LogLevel.PanicLevel, format, a...)
+	entry := logger.makeLog(LogLevel.PanicLevel, format, a...)
 
-	logger.output(log)
+	logger.output(entry)
 
 	os.Exit(1)
 }
 
+// enabled
+// @author Tianyi
+// @description 判断该等级的日志是否需要输出
+func (logger *yiLogger) enabled(level Level) bool {
+	return level >= logger.cfg.LogLevel
+}
+
 // makeLog
 // @author Tianyi
 // @description 生成日志内容
-func (logger *yiLogger) makeLog(logLevel Level, format string, a ...any) []byte {
+func (logger *yiLogger) makeLog(logLevel Level, format string, a ...any) *yiLogEntry {
 	// 格式化 msg
-	msg := formatMsg(format, a...)
+	msg := formatMsg(logger.cfg.KeepNewline, format, a...)
 	// 构建日志每行信息
-	entry := buildLogEntry(logger.cfg, logLevel, msg)
-	log, _ := json.Marshal(entry)
-	return log
+	return buildLogEntry(logger.cfg, logLevel, msg)
 }
 
 // output
 // @author Tianyi
 // @description 根据配置输出到文件或者控制台
-func (logger *yiLogger) output(log []byte) {
-	if !logger.statue {
+func (logger *yiLogger) output(entry *yiLogEntry) {
+	if !logger.statue.Load() {
 		return
 	}
+	if len(entry.Name) == 0 {
+		entry.Name = logger.name
+	}
 	if logger.cfg.OutputWay == OutPut.Default || logger.cfg.OutputWay == OutPut.Console {
-		fmt.Println(string(log))
-	} else {
-		logger.logCh <- log
+		logger.console.print(entry)
+		return
+	}
+
+	// 同步模式下等待日志写入磁盘，不经过预写队列
+	if logger.durable.needWait(entry.lvl) {
+		entry.done = make(chan struct{})
+		select {
+		case logger.logCh <- entry:
+		case <-logger.doneChan:
+			return
+		}
+		select {
+		case <-entry.done:
+		case <-logger.doneChan:
+		}
+		return
+	}
+
+	// 开启预写队列时，日志写入磁盘后才返回，写入失败时退回到内存通道
+	if logger.spool != nil {
+		record, err := encodeSpoolRecord(entry)
+		if err == nil && logger.spool.Append(record) == nil {
+			return
+		}
 	}
+	logger.logCh <- entry
 }
 
+// writer
+// @author Tianyi
+// @description 接收日志并写入文件，开启合并重复日志时，在这里完成合并
 func (logger *yiLogger) writer() {
-	var buf []byte
+	var dd *deduper
+	var tick <-chan time.Time
+	if logger.cfg.Dedup {
+		dd = newDeduper(logger.cfg.DedupWindow)
+		ticker := time.NewTicker(logger.cfg.DedupWindow)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var spoolNotify <-chan struct{}
+	if logger.spool != nil {
+		spoolNotify = logger.spool.Notify()
+	}
+
+	var hup chan os.Signal
+	if logger.cfg.ReopenOnSIGHUP {
+		hup = make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+	}
+
+	var syncTick <-chan time.Time
+	if logger.cfg.Sync == LogSync.Interval {
+		ticker := time.NewTicker(logger.cfg.SyncInterval)
+		defer ticker.Stop()
+		syncTick = ticker.C
+	}
+
+	var spoolTick <-chan time.Time
+	if logger.spool != nil {
+		ticker := time.NewTicker(spoolCommitInterval)
+		defer ticker.Stop()
+		spoolTick = ticker.C
+	}
+
+	defer close(logger.doneChan)
+
+	handle := func(entry *yiLogEntry) {
+		if dd == nil {
+			_ = logger.write(entry)
+			return
+		}
+		// 等待同步的日志不参与合并，先输出正在合并的日志
+		if entry.done != nil {
+			for _, e := range dd.flush() {
+				_ = logger.write(e)
+			}
+			_ = logger.write(entry)
+			return
+		}
+		for _, e := range dd.push(entry) {
+			_ = logger.write(e)
+		}
+		// 合并到之前日志中的日志随之前的日志一起送达，之前的日志确认前不会提交
+		if logger.spoolAck != nil && dd.pending != entry && dd.last == entry {
+			logger.spoolAck.ack(entry.pos, nil)
+		}
+	}
+
+	// drain 写完通道和预写队列中剩余的日志，并提交已经确认送达的日志
+	drain := func() {
+		for len(logger.logCh) > 0 {
+			handle(<-logger.logCh)
+		}
+		if logger.spool != nil {
+			logger.drainSpool(handle)
+			logger.commitSpool()
+		}
+	}
+
 	for {
 		select {
-		case buf = <-logger.logCh:
-			_ = logger.fo.Write(buf)
+		case entry := <-logger.logCh:
+			handle(entry)
+		case <-spoolNotify:
+			logger.drainSpool(handle)
+		case <-spoolTick:
+			// 定时提交，避免每条日志都重写提交位置
+			logger.commitSpool()
+		case ch := <-logger.reopenCh:
+			// 通道中的日志属于重新打开之前，先写入原来的文件
+			drain()
+			ch <- logger.reopen()
+		case <-hup:
+			drain()
+			_ = logger.reopen()
+		case <-syncTick:
+			if logger.durable.dirty() {
+				_ = logger.sync()
+			}
+		case <-tick:
+			for _, e := range dd.expire(logger.cfg.now()) {
+				_ = logger.write(e)
+			}
 		case <-logger.exitChan:
+			// 写完通道中剩余的日志
+			drain()
+			// 输出还未结束合并的日志
+			if dd != nil {
+				for _, e := range dd.flush() {
+					_ = logger.write(e)
+				}
+			}
 			// 关闭日志通道
 			close(logger.logCh)
-			// 关闭文件操作
-			_ = logger.fo.Close()
+			if logger.durable.dirty() {
+				_ = logger.sync()
+			}
+			// 关闭输出目标，异步输出目标关闭时会确认剩余日志的发送结果
+			_ = logger.sink.close()
+			if logger.spool != nil {
+				logger.commitSpool()
+				_ = logger.spool.Close()
+			}
 			return
 		}
 	}
 }
+
+// reopen
+// @author Tianyi
+// @description 重新打开输出目标，不支持的输出目标直接返回
+func (logger *yiLogger) reopen() error {
+	if r, ok := logger.sink.(reopener); ok {
+		return r.reopen()
+	}
+	return nil
+}
+
+// write
+// @author Tianyi
+// @description 序列化日志并写入输出目标，磁盘可用空间不足时丢弃低级别的日志，
+//				按照同步策略同步到磁盘，返回写入失败的原因，来自预写队列的日志写入失败时不会提交，
+//				等待同步的日志写入或者同步失败时调用 OnSyncError
+func (logger *yiLogger) write(entry *yiLogEntry) (err error) {
+	if entry.done != nil {
+		defer func() {
+			// 等待的日志没有写入磁盘时先通知调用方
+			if err != nil && logger.cfg.OnSyncError != nil {
+				logger.cfg.OnSyncError(err)
+			}
+			close(entry.done)
+		}()
+	}
+	if logger.spoolAck != nil {
+		defer func() {
+			logger.spoolAck.wrote(entry, err)
+		}()
+	}
+	if logger.guard != nil {
+		ok, warn := logger.guard.allow(logger.cfg, entry)
+		if warn != nil {
+			_ = logger.sink.write(warn, logger.cfg.Schema.encode(warn))
+		}
+		if !ok {
+			return errLowFreeSpace
+		}
+	}
+	err = logger.sink.write(entry, logger.cfg.Schema.encode(entry))
+
+	// 写入过程中产生的诊断日志
+	for len(logger.diag) != 0 {
+		d := logger.diag[0]
+		logger.diag = logger.diag[1:]
+		_ = logger.sink.write(d, logger.cfg.Schema.encode(d))
+	}
+
+	if logger.durable.wrote(entry.lvl) {
+		if e := logger.sync(); e != nil && err == nil && entry.done != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// notice
+// @author Tianyi
+// @description 生成输出目标自己发送的诊断日志，可以在任意协程中调用
+func (logger *yiLogger) notice(msg string) (*yiLogEntry, []byte) {
+	entry := newLogEntry(logger.cfg, LogLevel.WarnLevel, msg, logger.cfg.now(), "yi-logger", 0)
+	return entry, logger.cfg.Schema.encode(entry)
+}
+
+// repaired
+// @author Tianyi
+// @description 日志文件末尾不完整的行被修复之后记录一条诊断日志，在当前日志写入之后输出
+func (logger *yiLogger) repaired(path, corruptPath string, n int) {
+	msg := fmt.Sprintf("terminated truncated last line of %s with a newline", path)
+	if len(corruptPath) != 0 {
+		msg = fmt.Sprintf("moved truncated last line of %s (%d bytes) to %s", path, n, corruptPath)
+	}
+	logger.diag = append(logger.diag, newLogEntry(logger.cfg, LogLevel.WarnLevel, msg, logger.cfg.now(), "yi-logger", 0))
+}
@@ -1,8 +1,16 @@
 package logger
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Chentyit/yi-logger/file_op"
 	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -78,3 +86,601 @@ func TestWriteBigLog(t *testing.T) {
 	time.Sleep(time.Second * 20)
 	logger.Close()
 }
+
+func TestDedup(t *testing.T) {
+	ass := assert.New(t)
+
+	cfg := &YiLogConfig{
+		DateFormat: LogDateFormat.Default,
+		TimeFormat: LogTimeFormat.Default,
+	}
+	dd := newDeduper(time.Second)
+
+	var out []*yiLogEntry
+	for i := 0; i < 3; i++ {
+		out = append(out, dd.push(buildLogEntry(cfg, LogLevel.ErrorLevel, "dependency down"))...)
+	}
+	ass.Len(out, 0, "重复日志不应该立即输出")
+
+	out = dd.push(buildLogEntry(cfg, LogLevel.InfoLevel, "recovered"))
+	ass.Len(out, 1, "重复结束后应该输出合并的日志")
+	ass.Equal(3, out[0].Repeated, "重复次数错误")
+	ass.NotEmpty(out[0].FirstTime, "缺少首次出现时间")
+	ass.NotEmpty(out[0].LastTime, "缺少最后出现时间")
+
+	ass.Len(dd.expire(time.Now()), 0, "未超过窗口不应该输出")
+	out = dd.expire(time.Now().Add(time.Second))
+	ass.Len(out, 1, "超过窗口应该输出")
+	ass.Equal(0, out[0].Repeated, "单条日志不应该带有重复次数")
+
+	// 结构化字段或者链路追踪信息不同的日志不是重复日志
+	withFields := func(fields ...Field) *yiLogEntry {
+		entry := buildLogEntry(cfg, LogLevel.ErrorLevel, "request failed")
+		entry.Fields = fields
+		return entry
+	}
+	out = dd.push(withFields(Field{Key: "user", Value: "a"}, Field{Key: "ids", Value: []int{1}}))
+	out = append(out, dd.push(withFields(Field{Key: "user", Value: "a"}, Field{Key: "ids", Value: []int{1}}))...)
+	ass.Len(out, 0, "字段相同的日志应该合并")
+	out = dd.push(withFields(Field{Key: "user", Value: "b"}, Field{Key: "ids", Value: []int{1}}))
+	ass.Len(out, 1, "字段不同的日志不应该合并")
+	ass.Equal(2, out[0].Repeated, "重复次数错误")
+	traced := withFields(Field{Key: "user", Value: "b"}, Field{Key: "ids", Value: []int{1}})
+	traced.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	out = dd.push(traced)
+	ass.Len(out, 1, "trace_id 不同的日志不应该合并")
+	ass.Equal(0, out[0].Repeated, "单条日志不应该带有重复次数")
+
+	// 不同子日志输出的相同日志不是重复日志，路由规则按照日志名称匹配
+	named := func(name string) *yiLogEntry {
+		entry := buildLogEntry(cfg, LogLevel.ErrorLevel, "dependency down")
+		entry.Name = name
+		return entry
+	}
+	dd.flush()
+	out = dd.push(named("a"))
+	out = append(out, dd.push(named("b"))...)
+	ass.Len(out, 1, "日志名称不同的日志不应该合并")
+	ass.Equal("a", out[0].Name)
+	out = dd.flush()
+	ass.Len(out, 1)
+	ass.Equal("b", out[0].Name)
+}
+
+func TestTimeEncoder(t *testing.T) {
+	ass := assert.New(t)
+
+	cfg := &YiLogConfig{
+		TimeEncoder: LogTimeEncoder.RFC3339Nano,
+		TimeZone:    time.UTC,
+		EnableTs:    true,
+	}
+	_, err := New(cfg)
+	ass.Nil(err, err)
+
+	entry := buildLogEntry(cfg, LogLevel.InfoLevel, "test")
+	ts, err := time.Parse(time.RFC3339Nano, entry.DateTime.(string))
+	ass.Nil(err, err)
+	ass.Equal(time.UTC, ts.Location(), "时区错误")
+	ass.Equal(ts.UnixNano(), entry.Ts, "ts 字段错误")
+
+	cfg.TimeEncoder = LogTimeEncoder.UnixMilli
+	entry = buildLogEntry(cfg, LogLevel.InfoLevel, "test")
+	ass.IsType(int64(0), entry.DateTime, "unix 时间应该是数值")
+}
+
+func TestInvalidTimeLayout(t *testing.T) {
+	ass := assert.New(t)
+
+	_, err := BuildLoggerLink().SetTimeLayout("yyyy/MM/dd hh:HH:ss").BuildE()
+	ass.NotNil(err, "非法的时间格式应该报错")
+
+	_, err = BuildLoggerLink().SetTimeLayout(time.RFC1123Z).BuildE()
+	ass.Nil(err, err)
+
+	// 只有部分是时间元素的格式也不合法
+	_, err = BuildLoggerLink().SetTimeLayout("2006-01-02 garbage").BuildE()
+	ass.ErrorIs(err, errInvalidLayout, "包含未知内容的时间格式应该报错")
+	for _, layout := range []string{time.RFC3339Nano, time.Kitchen, time.StampMicro, time.ANSIC, "2006-01-02T15:04:05Z"} {
+		ass.Nil(validateLayout(layout), layout)
+	}
+
+	// Build 不返回错误，使用默认的时间格式
+	l := BuildLoggerLink().SetTimeLayout("2006-01-02 garbage").Build()
+	ass.NotNil(l)
+	ass.Empty(l.cfg.TimeLayout, "时间格式错误时应该使用默认格式")
+	ass.Equal(LogDateFormat.Default, l.cfg.DateFormat)
+
+	// 其他配置错误时输出到控制台
+	l = BuildLoggerLink().SetOutput(OutPut.HTTP).Build()
+	ass.NotNil(l)
+	ass.Equal(OutPut.Console, l.cfg.OutputWay, "配置错误时应该输出到控制台")
+}
+
+func TestFakeClock(t *testing.T) {
+	ass := assert.New(t)
+
+	clock := NewFakeClock(time.Date(2022, 6, 14, 16, 11, 29, 0, time.UTC))
+	cfg := &YiLogConfig{
+		DateFormat: LogDateFormat.Compact,
+		TimeFormat: LogTimeFormat.Compact,
+		TimeZone:   time.UTC,
+		Clock:      clock,
+	}
+
+	entry := buildLogEntry(cfg, LogLevel.InfoLevel, "test")
+	ass.Equal("20220614 161129", entry.DateTime, "日志时间错误")
+
+	clock.Add(time.Hour)
+	entry = buildLogEntry(cfg, LogLevel.InfoLevel, "test")
+	ass.Equal("20220614 171129", entry.DateTime, "日志时间错误")
+}
+
+func TestSchema(t *testing.T) {
+	ass := assert.New(t)
+
+	cfg := &YiLogConfig{
+		TimeEncoder: LogTimeEncoder.UnixSecond,
+		Clock:       NewFakeClock(time.Unix(1655194289, 0)),
+	}
+	entry := buildLogEntry(cfg, LogLevel.WarnLevel, "test")
+	entry.Trace = "main.go"
+	entry.Line = 12
+
+	ass.Equal(`{"time":1655194289,"trace":"main.go","line":12,"level":"WARN","message":"test"}`,
+		string(LogSchema.Default.encode(entry)), "默认字段错误")
+	ass.Equal(`{"@timestamp":1655194289,"log.origin.file.name":"main.go","log.origin.file.line":12,"log.level":"WARN","message":"test"}`,
+		string(LogSchema.ECS.encode(entry)), "ECS 字段错误")
+	ass.Equal(`{"time":1655194289,"logging.googleapis.com/sourceLocation":{"file":"main.go","line":12},"severity":"WARNING","message":"test"}`,
+		string(LogSchema.GCP.encode(entry)), "GCP 字段错误")
+	ass.Equal(`{"timeUnixNano":1655194289000000000,"code.filepath":"main.go","code.lineno":12,"SeverityText":"WARN","SeverityNumber":13,"Body":"test"}`,
+		string(LogSchema.OTel.encode(entry)), "OTel 字段错误")
+
+	// GCP 的 trace 需要带上项目 ID
+	traced := *entry
+	traced.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traced.SpanID = "00f067aa0ba902b7"
+	gcp := GCPSchema("my-project")
+	ass.Equal(`{"time":1655194289,"logging.googleapis.com/sourceLocation":{"file":"main.go","line":12},"severity":"WARNING","message":"test",`+
+		`"logging.googleapis.com/trace":"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736","logging.googleapis.com/spanId":"00f067aa0ba902b7"}`,
+		string(gcp.encode(&traced)), "GCP trace 格式错误")
+	ass.Contains(string(LogSchema.Default.encode(&traced)), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`, "其他映射不应该带前缀")
+
+	schema := LogSchema.Default
+	schema.Time = "@timestamp"
+	schema.Level = "log.level"
+	schema.Trace = ""
+	schema.Line = ""
+	schema.Caller = "caller"
+	ass.Equal(`{"@timestamp":1655194289,"caller":"main.go:12","log.level":"WARN","message":"test"}`,
+		string(schema.encode(entry)), "自定义字段错误")
+
+	// 和保留字段重名的结构化字段加上前缀，不能输出重复的 key
+	fielded := *entry
+	fielded.Fields = []Field{{Key: "message", Value: "user"}, {Key: "severity", Value: 1}, {Key: "user", Value: "a"}, {Key: "user", Value: "b"}}
+	ass.Equal(`{"time":1655194289,"trace":"main.go","line":12,"level":"WARN","message":"test",`+
+		`"fields.message":"user","severity":1,"user":"a","fields.user":"b"}`,
+		string(LogSchema.Default.encode(&fielded)), "重名字段错误")
+	ass.Contains(string(LogSchema.GCP.encode(&fielded)), `"severity":"WARNING","message":"test","labels.message":"user","labels.severity":1`)
+	ass.Contains(string(LogSchema.OTel.encode(&fielded)), `"Body":"test","message":"user"`)
+	fielded.Fields = []Field{{Key: "Body", Value: "x"}}
+	ass.Contains(string(LogSchema.OTel.encode(&fielded)), `"Body":"test","attributes.Body":"x"`)
+	ass.Contains(string(schema.encode(&fielded)), `"message":"test","Body":"x"`)
+	for _, s := range []EntrySchema{LogSchema.Default, LogSchema.ECS, LogSchema.GCP, LogSchema.OTel} {
+		fielded.Fields = []Field{{Key: s.Time, Value: "t"}, {Key: s.Level, Value: "l"}, {Key: s.Message, Value: "m"}}
+		var decoded map[string]any
+		ass.Nil(json.Unmarshal(s.encode(&fielded), &decoded))
+		ass.Equal("test", decoded[s.Message], "保留字段不能被覆盖")
+		ass.Equal("m", decoded[s.FieldPrefix+s.Message])
+		ass.Equal("l", decoded[s.FieldPrefix+s.Level])
+		ass.Equal("t", decoded[s.FieldPrefix+s.Time])
+	}
+}
+
+func TestMultiLineMessage(t *testing.T) {
+	ass := assert.New(t)
+
+	ass.Equal("select * from t where id = 1", formatMsg(false, "select *\nfrom t\rwhere id = %d", 1))
+	msg := formatMsg(true, "select *\nfrom t\twhere id = %d\x00\x1b[31m\xff\u0085\u2028", 1)
+	ass.Equal("select *\nfrom t\twhere id = 1\x00\x1b[31m�\u0085\u2028", msg, "应该保留原始内容")
+
+	entry := &yiLogEntry{DateTime: "t", Level: "INFO", Message: msg}
+	log := LogSchema.Default.encode(entry)
+	ass.Equal(`{"time":"t","trace":"","line":0,"level":"INFO","message":"select *\nfrom t\twhere id = 1\u0000\u001b[31m`+"�"+`\u0085\u2028"}`,
+		string(log), "转义错误")
+	ass.NotContains(string(log), "\n", "日志应该只有一行")
+
+	var decoded map[string]any
+	ass.Nil(json.Unmarshal(log, &decoded))
+	ass.Equal(msg, decoded["message"], "反序列化后内容应该一致")
+}
+
+func TestSpoolReplayOnRestart(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	spoolDir := filepath.Join(dir, "spool")
+
+	// 模拟日志已经写入预写队列，但是还没有写入文件时进程崩溃
+	sp, err := openSpool(&SpoolConfig{Dir: spoolDir})
+	ass.Nil(err, err)
+	record, err := encodeSpoolRecord(buildLogEntry(&YiLogConfig{TimeEncoder: LogTimeEncoder.UnixNano}, LogLevel.ErrorLevel, "before crash"))
+	ass.Nil(err, err)
+	ass.Nil(sp.Append(record))
+	ass.Nil(sp.Close())
+
+	file := filepath.Join(dir, "audit.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetSpool(&SpoolConfig{Dir: spoolDir}).
+		BuildE()
+	ass.Nil(err, err)
+	l.Info("after restart")
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	ass.Len(lines, 2, "应该重新发送未确认的日志")
+	ass.Contains(lines[0], `"level":"ERROR","message":"before crash"`)
+	ass.Regexp(`^\{"time":\d{19},`, lines[0], "unix 纳秒时间不应该丢失精度")
+	ass.Contains(lines[1], `"message":"after restart"`)
+
+	// 已经确认的日志不会再次发送
+	l, err = BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetSpool(&SpoolConfig{Dir: spoolDir}).
+		BuildE()
+	ass.Nil(err, err)
+	l.Close()
+	content, err = os.ReadFile(file)
+	ass.Nil(err, err)
+	ass.Len(strings.Split(strings.TrimSpace(string(content)), "\n"), 2)
+}
+
+func TestSpoolReplayAfterSinkFailure(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	spoolDir := filepath.Join(dir, "spool")
+
+	// 日志目录的位置是一个普通文件，写入日志文件失败
+	blocker := filepath.Join(dir, "blocker")
+	ass.Nil(os.WriteFile(blocker, nil, 0644))
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(blocker, "app.log")).
+		SetSpool(&SpoolConfig{Dir: spoolDir}).
+		BuildE()
+	ass.Nil(err, err)
+	l.Error("write failed")
+	l.Close()
+
+	// 重启后写入成功，之前写入失败的日志重新发送
+	file := filepath.Join(dir, "app.log")
+	l, err = BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetSpool(&SpoolConfig{Dir: spoolDir}).
+		BuildE()
+	ass.Nil(err, err)
+	l.Info("after restart")
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	ass.Len(lines, 2, "写入失败的日志不应该提交")
+	ass.Contains(lines[0], `"message":"write failed"`)
+	ass.Contains(lines[1], `"message":"after restart"`)
+}
+
+func TestSpoolReplayAfterUndelivered(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	spoolDir := filepath.Join(dir, "spool")
+
+	// 找一个没有监听的端口，日志只能缓存在内存中
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.Network).
+		SetNetwork(&NetworkConfig{Address: addr, MinBackoff: time.Hour}).
+		SetSpool(&SpoolConfig{Dir: spoolDir}).
+		BuildE()
+	ass.Nil(err, err)
+	l.Info("undelivered")
+	l.Close()
+
+	// 异步输出目标没有送达的日志在重启后重新发送
+	file := filepath.Join(dir, "app.log")
+	l, err = BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetSpool(&SpoolConfig{Dir: spoolDir}).
+		BuildE()
+	ass.Nil(err, err)
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	ass.Contains(string(content), `"message":"undelivered"`, "没有送达的日志不应该提交")
+}
+
+func TestSpoolAck(t *testing.T) {
+	ass := assert.New(t)
+
+	pos := func(off int64) file_op.SpoolPos {
+		return file_op.SpoolPos{Off: off}
+	}
+	a := &spoolAck{async: true}
+	for i := int64(1); i <= 3; i++ {
+		a.track(pos(i))
+	}
+
+	// 只提交连续确认的日志
+	a.ack(pos(2), nil)
+	_, ok := a.committable()
+	ass.False(ok, "第一条日志还没有确认")
+	a.ack(pos(1), nil)
+	p, ok := a.committable()
+	ass.True(ok)
+	ass.Equal(pos(2), p)
+
+	// 异步输出目标写入成功时不确认
+	a.wrote(&yiLogEntry{pos: pos(3)}, nil)
+	_, ok = a.committable()
+	ass.False(ok, "异步输出目标送达之前不应该提交")
+
+	// 发送失败的日志阻止提交，之后的日志送达后一起提交
+	a.ack(pos(3), errDropped)
+	_, ok = a.committable()
+	ass.False(ok, "发送失败的日志不应该提交")
+	a.track(pos(4))
+	a.track(pos(5))
+	a.ack(pos(5), nil)
+	p, ok = a.committable()
+	ass.True(ok)
+	ass.Equal(pos(3), p, "第四条日志还没有确认")
+	a.ack(pos(4), nil)
+	p, ok = a.committable()
+	ass.True(ok)
+	ass.Equal(pos(5), p)
+
+	// 主动丢弃的日志视为已确认
+	a.track(pos(6))
+	a.track(pos(7))
+	a.ack(pos(6), errDiscarded)
+	a.ack(pos(7), errLowFreeSpace)
+	p, ok = a.committable()
+	ass.True(ok)
+	ass.Equal(pos(7), p)
+}
+
+func TestSpoolCommitInterval(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	spoolDir := filepath.Join(dir, "spool")
+	file := filepath.Join(dir, "app.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetSpool(&SpoolConfig{Dir: spoolDir}).
+		BuildE()
+	ass.Nil(err, err)
+
+	// 日志写入后不立即提交，定时或者关闭时提交
+	l.Info("pending commit")
+	ass.Eventually(func() bool {
+		content, _ := os.ReadFile(file)
+		return strings.Contains(string(content), "pending commit")
+	}, time.Second, time.Millisecond)
+	ass.NoFileExists(filepath.Join(spoolDir, "commit"), "每条日志写入后不应该立即提交")
+
+	l.Close()
+	ass.FileExists(filepath.Join(spoolDir, "commit"), "关闭时应该提交")
+}
+
+func TestSpoolAckAfterFailure(t *testing.T) {
+	ass := assert.New(t)
+
+	// 每个段最多保存 1 条记录
+	dir := t.TempDir()
+	sp, err := file_op.OpenSpool(dir, 10, 0)
+	ass.Nil(err, err)
+	defer sp.Close()
+	for i := 0; i < 4; i++ {
+		ass.Nil(sp.Append([]byte(fmt.Sprintf("record-%d", i))))
+	}
+
+	// 第二条日志发送失败，之后的日志送达后仍然继续提交
+	a := &spoolAck{}
+	for i := 0; i < 4; i++ {
+		_, ok, err := sp.Next()
+		ass.True(ok)
+		ass.Nil(err, err)
+		a.track(sp.Pos())
+		if i == 1 {
+			a.ack(sp.Pos(), errors.New("write failed"))
+		} else {
+			a.ack(sp.Pos(), nil)
+		}
+		if pos, ok := a.committable(); ok {
+			ass.Nil(sp.CommitTo(pos))
+		}
+	}
+	ass.Empty(a.pending, "所有日志都应该提交")
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	ass.Nil(err, err)
+	ass.Len(segments, 1, "已经提交的段文件应该删除")
+}
+
+func TestCloseTwice(t *testing.T) {
+	ass := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "app.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		BuildE()
+	ass.Nil(err, err)
+
+	child := l.Named("child")
+	child.Info("from child")
+
+	// 子 Logger 和根 Logger 都关闭，或者多个协程同时关闭时不应该 panic
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ass.NotPanics(child.Close)
+		}()
+	}
+	wg.Wait()
+	ass.NotPanics(l.Close)
+	l.Info("after close")
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	ass.Contains(string(content), `"message":"from child"`, "关闭时应该写完之前的日志")
+	ass.NotContains(string(content), "after close", "关闭后不应该再写入")
+}
+
+func TestReopen(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		BuildE()
+	ass.Nil(err, err)
+
+	l.Info("before rotate")
+	ass.Nil(l.Reopen(), "重新打开日志文件失败")
+	// 模拟 logrotate 移动日志文件
+	ass.Nil(os.Rename(file, file+".1"))
+	ass.Nil(l.Reopen(), "重新打开日志文件失败")
+	l.Info("after rotate")
+	l.Close()
+	ass.NotNil(l.Reopen(), "关闭后不能重新打开")
+
+	content, err := os.ReadFile(file + ".1")
+	ass.Nil(err, err)
+	ass.Contains(string(content), `"message":"before rotate"`)
+	ass.NotContains(string(content), `"message":"after rotate"`)
+
+	content, err = os.ReadFile(file)
+	ass.Nil(err, err)
+	ass.Contains(string(content), `"message":"after rotate"`, "重新打开后应该写入新的文件")
+}
+
+func TestRepairTruncatedLine(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	// 上一次运行时在写入日志的过程中被杀死
+	ass.Nil(os.WriteFile(file, []byte(`{"time":"2022-06-14 12:00:00","level":"INFO","mess`), 0644))
+
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		BuildE()
+	ass.Nil(err, err)
+	l.Info("after restart")
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	ass.Len(lines, 2)
+	for _, line := range lines {
+		ass.True(json.Valid([]byte(line)), "每一行都应该是合法的 Json: %s", line)
+	}
+	ass.Contains(lines[0], `"message":"after restart"`)
+	ass.Contains(lines[1], `"level":"WARN"`, "修复后应该输出诊断日志")
+	ass.Contains(lines[1], file+".corrupt")
+	ass.FileExists(file + ".corrupt")
+}
+
+func TestFileRepairConfig(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	partial := `{"time":"2022-06-14 12:00:00","level":"INFO","mess`
+
+	// 只补上换行
+	file := filepath.Join(dir, "newline.log")
+	ass.Nil(os.WriteFile(file, []byte(partial), 0644))
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetFileRepair(LogFileRepair.Newline).
+		BuildE()
+	ass.Nil(err, err)
+	l.Info("after restart")
+	l.Close()
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	ass.True(strings.HasPrefix(string(content), partial+"\n"), "不完整的行应该保留并补上换行")
+	ass.NoFileExists(file + ".corrupt")
+
+	// 不修复
+	file = filepath.Join(dir, "off.log")
+	ass.Nil(os.WriteFile(file, []byte(partial), 0644))
+	l, err = BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetFileRepair(LogFileRepair.Off).
+		BuildE()
+	ass.Nil(err, err)
+	l.Info("after restart")
+	l.Close()
+	content, err = os.ReadFile(file)
+	ass.Nil(err, err)
+	ass.True(strings.HasPrefix(string(content), partial+`{"time"`), "不修复时直接写在后面")
+	ass.NoFileExists(file + ".corrupt")
+
+	_, err = BuildLoggerLink().SetFileRepair(LogFileRepair.Off + 1).BuildE()
+	ass.NotNil(err, "未知的修复方式应该返回错误")
+}
+
+func TestFileTemplate(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Date(2022, 6, 14, 23, 59, 0, 0, time.Local))
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(dir, "{date}", "{service}.log")).
+		SetService("billing").
+		SetClock(clock).
+		BuildE()
+	ass.Nil(err, err)
+
+	l.Info("day 1")
+	ass.Nil(l.Reopen())
+	clock.Add(2 * time.Minute)
+	l.Info("day 2")
+	l.Close()
+
+	content, err := os.ReadFile(filepath.Join(dir, "2022-06-14", "billing.log"))
+	ass.Nil(err, err)
+	ass.Contains(string(content), `"message":"day 1"`)
+	ass.NotContains(string(content), `"message":"day 2"`)
+
+	content, err = os.ReadFile(filepath.Join(dir, "2022-06-15", "billing.log"))
+	ass.Nil(err, err)
+	ass.Contains(string(content), `"message":"day 2"`, "跨天后应该写入新的目录")
+}
@@ -0,0 +1,314 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// msgpackEncoder
+// @author Tianyi
+// @description 只实现 Fluentd Forward 协议需要的 MessagePack 编码，
+//				避免为了一个输出方式引入第三方依赖
+type msgpackEncoder struct {
+	buf []byte
+}
+
+// encode
+// @author Tianyi
+// @description 编码任意值，map 的 key 按顺序输出保证结果稳定
+func (e *msgpackEncoder) encode(v any) {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case int:
+		e.encodeInt(int64(v))
+	case int64:
+		e.encodeInt(v)
+	case float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.encodeInt(i)
+		} else if f, err := v.Float64(); err == nil {
+			e.encode(f)
+		} else {
+			e.encodeString(v.String())
+		}
+	case string:
+		e.encodeString(v)
+	case []byte:
+		e.encodeBin(v)
+	case time.Time:
+		e.encodeEventTime(v)
+	case []any:
+		e.encodeArrayHeader(len(v))
+		for _, item := range v {
+			e.encode(item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.encodeMapHeader(len(v))
+		for _, k := range keys {
+			e.encodeString(k)
+			e.encode(v[k])
+		}
+	default:
+		e.encodeString(fmt.Sprint(v))
+	}
+}
+
+func (e *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		e.buf = append(e.buf, byte(i))
+	case i < 0 && i >= -32:
+		e.buf = append(e.buf, byte(int8(i)))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) encodeBin(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *msgpackEncoder) encodeArrayHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xdc)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdd)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeMapHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xde)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdf)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// encodeEventTime
+// @author Tianyi
+// @description Fluentd EventTime 扩展类型 (ext type 0): 秒和纳秒各 4 字节
+func (e *msgpackEncoder) encodeEventTime(t time.Time) {
+	e.buf = append(e.buf, 0xd7, 0x00)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Unix()))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Nanosecond()))
+}
+
+// msgpackExt MessagePack 扩展类型
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// msgpackDecode
+// @author Tianyi
+// @description 解码一个 MessagePack 值，用于读取 Fluentd 的 ack 响应
+func msgpackDecode(r *bufio.Reader) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return msgpackDecodeMap(r, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return msgpackDecodeArray(r, int(b&0x0f))
+	case b&0xe0 == 0xa0:
+		return msgpackReadString(r, int(b&0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := msgpackReadLength(r, b-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackReadBytes(r, n)
+	case 0xca:
+		v, err := msgpackReadUint(r, 4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := msgpackReadUint(r, 8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := msgpackReadUint(r, 1<<(b-0xcc))
+		return int64(v), err
+	case 0xd0:
+		v, err := msgpackReadUint(r, 1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := msgpackReadUint(r, 2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := msgpackReadUint(r, 4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := msgpackReadUint(r, 8)
+		return int64(v), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return msgpackReadExt(r, 1<<(b-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := msgpackReadLength(r, b-0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackReadExt(r, n)
+	case 0xd9, 0xda, 0xdb:
+		n, err := msgpackReadLength(r, b-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackReadString(r, n)
+	case 0xdc, 0xdd:
+		n, err := msgpackReadLength(r, b-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeArray(r, n)
+	case 0xde, 0xdf:
+		n, err := msgpackReadLength(r, b-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeMap(r, n)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%x", b)
+}
+
+// msgpackReadLength
+// @author Tianyi
+// @description 读取长度，size 为 0、1、2 时分别对应 1、2、4 字节
+func msgpackReadLength(r *bufio.Reader, size byte) (int, error) {
+	v, err := msgpackReadUint(r, 1<<size)
+	return int(v), err
+}
+
+func msgpackReadUint(r *bufio.Reader, n int) (uint64, error) {
+	b, err := msgpackReadBytes(r, n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func msgpackReadBytes(r *bufio.Reader, n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func msgpackReadString(r *bufio.Reader, n int) (string, error) {
+	b, err := msgpackReadBytes(r, n)
+	return string(b), err
+}
+
+func msgpackReadExt(r *bufio.Reader, n int) (any, error) {
+	t, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := msgpackReadBytes(r, n)
+	return msgpackExt{Type: int8(t), Data: data}, err
+}
+
+func msgpackDecodeArray(r *bufio.Reader, n int) ([]any, error) {
+	arr := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func msgpackDecodeMap(r *bufio.Reader, n int) (map[string]any, error) {
+	m := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		k, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
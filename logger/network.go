@@ -0,0 +1,230 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// NetworkConfig
+// @author Tianyi
+// @description TCP/UDP 输出配置，每条日志作为一行 Json 发送，适用于 Fluent Bit、Vector 等
+type NetworkConfig struct {
+	Network      string        // 传输方式: tcp、udp (默认: tcp)
+	Address      string        // 地址
+	TLS          bool          // 是否使用 TLS (仅 tcp)
+	CAFile       string        // 校验服务端证书的 CA 证书 (默认: 系统证书)
+	CertFile     string        // 客户端证书
+	KeyFile      string        // 客户端证书私钥
+	ServerName   string        // 校验服务端证书时使用的名称 (默认: 地址中的主机名)
+	TLSConfig    *tls.Config   // 自定义 TLS 配置，设置后忽略上面的证书配置
+	DialTimeout  time.Duration // 连接超时时间 (默认: 5s)
+	WriteTimeout time.Duration // 写入超时时间，避免对端太慢阻塞发送 (默认: 5s)
+	BufferSize   int           // 断开连接时最多缓存的字节数，超过后丢弃最早的日志 (默认: 8MB)
+	MinBackoff   time.Duration // 第一次重连的等待时间，之后每次翻倍 (默认: 100ms)
+	MaxBackoff   time.Duration // 重连最长等待时间 (默认: 30s)
+}
+
+// netSink
+// @author Tianyi
+// @description 通过 TCP/UDP 发送 Json 行，发送在单独的协程中完成，writer 协程只负责
+//				放入缓冲区，连接断开时按照指数退避重连，期间的日志缓存在内存中，
+//				缓冲区满时丢弃的日志条数在之后的发送中作为一条诊断日志发送
+type netSink struct {
+	*asyncQueue
+	cfg       NetworkConfig
+	tlsConfig *tls.Config
+	conn      net.Conn // 只在发送协程中使用
+}
+
+// newNetSink
+// @author Tianyi
+// @description 构建 TCP/UDP 输出目标并启动发送协程
+func newNetSink(cfg *NetworkConfig) (*netSink, error) {
+	if cfg == nil || len(cfg.Address) == 0 {
+		return nil, errors.New("network address is required")
+	}
+	c := *cfg
+
+	if len(c.Network) == 0 {
+		c.Network = "tcp"
+	}
+	switch c.Network {
+	case "tcp", "tcp4", "tcp6":
+	case "udp", "udp4", "udp6":
+		if c.TLS || c.TLSConfig != nil {
+			return nil, errors.New("tls is not supported over udp")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported network: %s", c.Network)
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 5 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 5 * time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 8 * 1024 * 1024
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 30 * time.Second
+	}
+
+	s := &netSink{
+		asyncQueue: newAsyncQueue(c.Address, c.BufferSize, true, appendNewline),
+		cfg:        c,
+	}
+	if c.TLSConfig != nil {
+		s.tlsConfig = c.TLSConfig
+	} else if c.TLS {
+		tlsConfig, err := buildTLSConfig(&c)
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig = tlsConfig
+	}
+
+	go s.run(s.flush, c.MinBackoff, c.MaxBackoff)
+	return s, nil
+}
+
+// buildTLSConfig
+// @author Tianyi
+// @description 根据证书文件构建 TLS 配置
+func buildTLSConfig(cfg *NetworkConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: cfg.ServerName}
+
+	if len(cfg.CAFile) != 0 {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(cfg.CertFile) != 0 || len(cfg.KeyFile) != 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// appendNewline
+// @author Tianyi
+// @description 复制日志并在末尾加上换行
+func appendNewline(_ *yiLogEntry, log []byte) []byte {
+	line := make([]byte, len(log)+1)
+	copy(line, log)
+	line[len(log)] = '\n'
+	return line
+}
+
+func (s *netSink) write(entry *yiLogEntry, log []byte) error {
+	s.push(entry, log)
+	s.notify()
+	return nil
+}
+
+func (s *netSink) close() error {
+	// 关闭时还没有发送的日志，已经无法通过连接通知，输出到标准错误
+	s.shutdown()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// flush
+// @author Tianyi
+// @description 发送缓冲区中的所有日志，失败时断开连接，未发送的日志放回缓冲区
+func (s *netSink) flush() error {
+	lines := s.take(0)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	sent, err := s.send(lines)
+	if err != nil {
+		if s.conn != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+		}
+		s.requeue(lines[sent:])
+	}
+	return err
+}
+
+// send
+// @author Tianyi
+// @description 发送日志并通知发送结果，返回已经处理的条数，
+//				UDP 超过数据报大小限制的日志无法发送，直接丢弃
+func (s *netSink) send(lines []queued) (int, error) {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return 0, err
+		}
+	}
+
+	// UDP 每条日志一个数据报
+	if _, ok := s.conn.(*net.UDPConn); ok {
+		for i, line := range lines {
+			_ = s.conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+			_, err := s.conn.Write(line.data)
+			switch {
+			case err == nil:
+				s.sent(lines[i:i+1], nil)
+			case tooLarge(err):
+				s.drop(lines[i : i+1])
+			default:
+				return i, err
+			}
+		}
+		return len(lines), nil
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line.data)
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		// 无法确定对端收到了多少，全部重新发送 (至少一次)
+		return 0, err
+	}
+	s.sent(lines, nil)
+	return len(lines), nil
+}
+
+// dial
+// @author Tianyi
+// @description 建立连接
+func (s *netSink) dial() error {
+	dialer := &net.Dialer{Timeout: s.cfg.DialTimeout}
+
+	var err error
+	if s.tlsConfig != nil {
+		s.conn, err = tls.DialWithDialer(dialer, s.cfg.Network, s.cfg.Address, s.tlsConfig)
+	} else {
+		s.conn, err = dialer.Dial(s.cfg.Network, s.cfg.Address)
+	}
+	if err != nil {
+		s.conn = nil
+	}
+	return err
+}
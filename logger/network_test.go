@@ -0,0 +1,181 @@
+package logger
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readLines(t *testing.T, conn net.Conn, n int) []string {
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	var lines []string
+	for i := 0; i < n; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	return lines
+}
+
+func TestNetSinkReconnect(t *testing.T) {
+	ass := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	addr := ln.Addr().String()
+
+	s, err := newNetSink(&NetworkConfig{
+		Address:    addr,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+	})
+	ass.Nil(err, err)
+
+	ass.Nil(s.write(nil, []byte(`{"message":"first"}`)))
+	conn, err := ln.Accept()
+	ass.Nil(err, err)
+	ass.Equal([]string{`{"message":"first"}`}, readLines(t, conn, 1))
+
+	// 服务端宕机，期间的日志缓存在内存中
+	_ = conn.Close()
+	_ = ln.Close()
+	for i := 0; i < 3; i++ {
+		ass.Nil(s.write(nil, []byte(`{"message":"buffered"}`)))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// 服务端恢复后自动重连并发送缓存的日志
+	ln, err = net.Listen("tcp", addr)
+	ass.Nil(err, err)
+	defer ln.Close()
+	conn, err = ln.Accept()
+	ass.Nil(err, err)
+	defer conn.Close()
+
+	lines := readLines(t, conn, 1)
+	ass.Equal(`{"message":"buffered"}`, lines[0])
+	ass.Nil(s.close())
+}
+
+func TestNetSinkDroppedNotice(t *testing.T) {
+	ass := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	s, err := newNetSink(&NetworkConfig{
+		Address:    addr,
+		BufferSize: 30,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+	})
+	ass.Nil(err, err)
+	s.notice = func(msg string) (*yiLogEntry, []byte) {
+		return nil, []byte(`{"message":"` + msg + `"}`)
+	}
+
+	// 服务端不可用时缓冲区满，丢弃最早的日志
+	for _, msg := range []string{"aaaaaaaaa", "bbbbbbbbb", "ccccccccc", "ddddddddd"} {
+		ass.Nil(s.write(nil, []byte(msg)))
+	}
+
+	// 重新连接后先发送丢弃日志的提示
+	ln, err = net.Listen("tcp", addr)
+	ass.Nil(err, err)
+	defer ln.Close()
+	conn, err := ln.Accept()
+	ass.Nil(err, err)
+	defer conn.Close()
+	lines := readLines(t, conn, 4)
+	ass.Equal(`{"message":"dropped 1 entries while `+addr+` was unavailable"}`, lines[0])
+	ass.Equal([]string{"bbbbbbbbb", "ccccccccc", "ddddddddd"}, lines[1:])
+	ass.Nil(s.close())
+	ass.Equal(s.dropped, s.reported, "丢弃的日志应该全部通知")
+}
+
+func TestNetSinkBufferLimit(t *testing.T) {
+	ass := assert.New(t)
+
+	s := &netSink{asyncQueue: newAsyncQueue("", 30, true, appendNewline)}
+	for _, msg := range []string{"aaaaaaaaa", "bbbbbbbbb", "ccccccccc", "ddddddddd"} {
+		ass.Nil(s.write(nil, []byte(msg)))
+	}
+	ass.Equal(1, s.dropped, "超过缓冲区大小时应该丢弃最早的日志")
+	ass.Equal("bbbbbbbbb\n", string(s.items[0].data))
+	ass.Equal(30, s.size)
+}
+
+func TestNetSinkUDPTooLarge(t *testing.T) {
+	ass := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	defer pc.Close()
+
+	s, err := newNetSink(&NetworkConfig{Network: "udp", Address: pc.LocalAddr().String()})
+	ass.Nil(err, err)
+	defer s.close()
+
+	// 超过 UDP 数据报大小限制的日志丢弃，不影响后面的日志
+	ass.Nil(s.write(nil, []byte(strings.Repeat("x", 70000))))
+	ass.Nil(s.write(nil, []byte("small")))
+
+	buf := make([]byte, 2048)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	ass.Nil(err, err)
+	ass.Equal("small\n", string(buf[:n]))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ass.Equal(1, s.dropped, "过大的日志应该计入丢弃条数")
+}
+
+func TestNetSinkTLS(t *testing.T) {
+	ass := assert.New(t)
+
+	// 借用 httptest 的自签名证书
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	ass.Nil(err, err)
+	defer ln.Close()
+
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.Network).
+		SetNetwork(&NetworkConfig{
+			Address:   ln.Addr().String(),
+			TLSConfig: &tls.Config{RootCAs: pool, ServerName: "example.com"},
+		}).
+		BuildE()
+	ass.Nil(err, err)
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	l.Info("over tls")
+	conn := <-accepted
+	defer conn.Close()
+	lines := readLines(t, conn, 1)
+	ass.Contains(lines[0], `"message":"over tls"`)
+	l.Close()
+}
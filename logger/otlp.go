@@ -0,0 +1,328 @@
+package logger
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// otlpScopeName 没有名称的 logger 使用的 InstrumentationScope 名称
+const otlpScopeName = "yi-logger"
+
+// otlpAttr OTLP 属性
+type otlpAttr struct {
+	key   string
+	value any // string、bool、int64、float64
+}
+
+// otlpRecord
+// @author Tianyi
+// @description OTLP LogRecord，由 JSON 和 protobuf 两种编码共用
+type otlpRecord struct {
+	time     int64
+	observed int64
+	severity int
+	level    string
+	body     string
+	attrs    []otlpAttr
+	traceID  []byte
+	spanID   []byte
+}
+
+// otlpScope 同一个 logger 名称下的日志
+type otlpScope struct {
+	name    string
+	records []otlpRecord
+}
+
+// buildOTLPScopes
+// @author Tianyi
+// @description 将日志按照 logger 名称分组，logger 名称作为 InstrumentationScope 名称，
+//				文件路径、行数和结构化字段作为 LogRecord 的属性
+func buildOTLPScopes(items []httpItem) []otlpScope {
+	observed := time.Now().UnixNano()
+
+	var scopes []otlpScope
+	index := make(map[string]int)
+	for _, item := range items {
+		entry := item.entry
+
+		name := entry.Name
+		if len(name) == 0 {
+			name = otlpScopeName
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(scopes)
+			index[name] = i
+			scopes = append(scopes, otlpScope{name: name})
+		}
+
+		attrs := make([]otlpAttr, 0, len(entry.Fields)+3)
+		attrs = append(attrs, otlpAttr{"code.filepath", entry.Trace}, otlpAttr{"code.lineno", int64(entry.Line)})
+		for _, f := range entry.Fields {
+			attrs = append(attrs, otlpAttr{f.Key, otlpValue(f.Value)})
+		}
+		if entry.Repeated != 0 {
+			attrs = append(attrs, otlpAttr{"repeated", int64(entry.Repeated)})
+		}
+
+		record := otlpRecord{
+			time:     item.ts,
+			observed: observed,
+			severity: otelSeverity[entry.lvl],
+			level:    entry.Level,
+			body:     entry.Message,
+			attrs:    attrs,
+		}
+		// 不合法的 trace_id 和 span_id 不输出
+		if traceID, err := hex.DecodeString(entry.TraceID); err == nil && len(traceID) == 16 {
+			record.traceID = traceID
+			if spanID, err := hex.DecodeString(entry.SpanID); err == nil && len(spanID) == 8 {
+				record.spanID = spanID
+			}
+		}
+		scopes[i].records = append(scopes[i].records, record)
+	}
+	return scopes
+}
+
+// otlpValue
+// @author Tianyi
+// @description 将字段值转换为 OTLP AnyValue 支持的类型，其他类型序列化为 Json 字符串
+func otlpValue(v any) any {
+	switch v := v.(type) {
+	case string, bool, int64, float64:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// otlpResource
+// @author Tianyi
+// @description 将 Labels 转换为 Resource 属性，按照名称排序保证结果稳定
+func otlpResource(labels map[string]string) []otlpAttr {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]otlpAttr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, otlpAttr{k, labels[k]})
+	}
+	return attrs
+}
+
+// encodeOTLPJSON
+// @author Tianyi
+// @description 按照 OTLP/HTTP JSON 编码生成 ExportLogsServiceRequest，
+//				64 位整数使用字符串，trace_id 和 span_id 使用十六进制
+func encodeOTLPJSON(resource []otlpAttr, scopes []otlpScope) []byte {
+	type object = map[string]any
+
+	jsonAttrs := func(attrs []otlpAttr) []object {
+		out := make([]object, 0, len(attrs))
+		for _, attr := range attrs {
+			var value object
+			switch v := attr.value.(type) {
+			case string:
+				value = object{"stringValue": v}
+			case bool:
+				value = object{"boolValue": v}
+			case int64:
+				value = object{"intValue": strconv.FormatInt(v, 10)}
+			case float64:
+				value = object{"doubleValue": v}
+			}
+			out = append(out, object{"key": attr.key, "value": value})
+		}
+		return out
+	}
+
+	scopeLogs := make([]object, 0, len(scopes))
+	for _, scope := range scopes {
+		records := make([]object, 0, len(scope.records))
+		for _, r := range scope.records {
+			record := object{
+				"timeUnixNano":         strconv.FormatInt(r.time, 10),
+				"observedTimeUnixNano": strconv.FormatInt(r.observed, 10),
+				"severityNumber":       r.severity,
+				"severityText":         r.level,
+				"body":                 object{"stringValue": r.body},
+				"attributes":           jsonAttrs(r.attrs),
+			}
+			if r.traceID != nil {
+				record["traceId"] = hex.EncodeToString(r.traceID)
+			}
+			if r.spanID != nil {
+				record["spanId"] = hex.EncodeToString(r.spanID)
+			}
+			records = append(records, record)
+		}
+		scopeLogs = append(scopeLogs, object{
+			"scope":      object{"name": scope.name},
+			"logRecords": records,
+		})
+	}
+
+	req := object{
+		"resourceLogs": []object{{
+			"resource":  object{"attributes": jsonAttrs(resource)},
+			"scopeLogs": scopeLogs,
+		}},
+	}
+	body, _ := json.Marshal(req)
+	return body
+}
+
+// encodeOTLPProto
+// @author Tianyi
+// @description 按照 opentelemetry-proto 的字段编号生成 protobuf 编码的
+//				ExportLogsServiceRequest，避免为了一个输出格式引入 protobuf 依赖
+func encodeOTLPProto(resource []otlpAttr, scopes []otlpScope) []byte {
+	res := &protoEncoder{}
+	for _, attr := range resource {
+		res.message(1, encodeProtoAttr(attr)) // Resource.attributes
+	}
+
+	resourceLogs := &protoEncoder{}
+	resourceLogs.message(1, res.buf) // ResourceLogs.resource
+	for _, scope := range scopes {
+		scopeInfo := &protoEncoder{}
+		scopeInfo.string(1, scope.name) // InstrumentationScope.name
+
+		scopeLogs := &protoEncoder{}
+		scopeLogs.message(1, scopeInfo.buf) // ScopeLogs.scope
+		for _, r := range scope.records {
+			record := &protoEncoder{}
+			record.fixed64(1, uint64(r.time))    // time_unix_nano
+			record.varint(2, uint64(r.severity)) // severity_number
+			record.string(3, r.level)            // severity_text
+			body := &protoEncoder{}
+			body.string(1, r.body)
+			record.message(5, body.buf) // body
+			for _, attr := range r.attrs {
+				record.message(6, encodeProtoAttr(attr)) // attributes
+			}
+			if r.traceID != nil {
+				record.bytes(9, r.traceID) // trace_id
+			}
+			if r.spanID != nil {
+				record.bytes(10, r.spanID) // span_id
+			}
+			record.fixed64(11, uint64(r.observed)) // observed_time_unix_nano
+			scopeLogs.message(2, record.buf)       // ScopeLogs.log_records
+		}
+		resourceLogs.message(2, scopeLogs.buf) // ResourceLogs.scope_logs
+	}
+
+	req := &protoEncoder{}
+	req.message(1, resourceLogs.buf) // ExportLogsServiceRequest.resource_logs
+	return req.buf
+}
+
+// encodeProtoAttr
+// @author Tianyi
+// @description 编码 KeyValue 和 AnyValue
+func encodeProtoAttr(attr otlpAttr) []byte {
+	value := &protoEncoder{}
+	switch v := attr.value.(type) {
+	case string:
+		value.string(1, v)
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		value.varint(2, b)
+	case int64:
+		value.varint(3, uint64(v))
+	case float64:
+		value.fixed64(4, math.Float64bits(v))
+	}
+
+	kv := &protoEncoder{}
+	kv.string(1, attr.key)
+	kv.message(2, value.buf)
+	return kv.buf
+}
+
+// protoEncoder
+// @author Tianyi
+// @description 只实现 OTLP 日志需要的 protobuf 编码
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) tag(field int, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *protoEncoder) varint(field int, v uint64) {
+	e.tag(field, 0)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *protoEncoder) fixed64(field int, v uint64) {
+	e.tag(field, 1)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+func (e *protoEncoder) bytes(field int, b []byte) {
+	e.tag(field, 2)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *protoEncoder) string(field int, s string) {
+	e.tag(field, 2)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *protoEncoder) message(field int, b []byte) {
+	e.bytes(field, b)
+}
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+// fakeOTLPReceiver 模拟 OTLP/HTTP 接收端，保存收到的请求体
+func fakeOTLPReceiver(t *testing.T, contentType string) (*httptest.Server, func() [][]byte) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != contentType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies
+	}
+}
+
+func TestOTLPJSON(t *testing.T) {
+	ass := assert.New(t)
+
+	server, bodies := fakeOTLPReceiver(t, "application/json")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.HTTP).
+		SetHTTP(&HTTPConfig{
+			URL:           server.URL + "/v1/logs",
+			Format:        LogHTTPFormat.OTLPJSON,
+			Labels:        map[string]string{"service.name": "checkout"},
+			FlushInterval: time.Hour,
+		}).
+		BuildE()
+	ass.Nil(err, err)
+
+	ctx := ContextWithSpan(context.Background(), testTraceID, testSpanID)
+	l.InfoCtx(ctx, "order %d created", 7)
+	slog.New(NewSlogHandler(l.Named("db"))).ErrorContext(ctx, "query failed", "rows", 3, "table", "orders")
+	l.Warn("no span")
+	l.Close()
+
+	ass.Len(bodies(), 1)
+	var req struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []map[string]any `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano   string           `json:"timeUnixNano"`
+					SeverityNumber int              `json:"severityNumber"`
+					SeverityText   string           `json:"severityText"`
+					Body           map[string]any   `json:"body"`
+					Attributes     []map[string]any `json:"attributes"`
+					TraceID        string           `json:"traceId"`
+					SpanID         string           `json:"spanId"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	ass.Nil(json.Unmarshal(bodies()[0], &req))
+	ass.Len(req.ResourceLogs, 1)
+
+	resource := req.ResourceLogs[0]
+	ass.Equal([]map[string]any{{"key": "service.name", "value": map[string]any{"stringValue": "checkout"}}}, resource.Resource.Attributes)
+	ass.Len(resource.ScopeLogs, 2, "应该按照 logger 名称分组")
+	ass.Equal("yi-logger", resource.ScopeLogs[0].Scope.Name)
+	ass.Equal("db", resource.ScopeLogs[1].Scope.Name)
+
+	records := resource.ScopeLogs[0].LogRecords
+	ass.Len(records, 2)
+	ass.Equal(9, records[0].SeverityNumber, "INFO 对应 SeverityNumber 9")
+	ass.Equal("INFO", records[0].SeverityText)
+	ass.Equal(map[string]any{"stringValue": "order 7 created"}, records[0].Body)
+	ass.NotEmpty(records[0].TimeUnixNano)
+	ass.Equal(testTraceID, records[0].TraceID)
+	ass.Equal(testSpanID, records[0].SpanID)
+	ass.Equal("code.filepath", records[0].Attributes[0]["key"])
+	ass.True(strings.HasSuffix(records[0].Attributes[0]["value"].(map[string]any)["stringValue"].(string), "otlp_test.go"), "调用位置错误")
+	ass.Equal(13, records[1].SeverityNumber, "WARN 对应 SeverityNumber 13")
+	ass.Empty(records[1].TraceID, "没有 span 时不应该输出 traceId")
+
+	db := resource.ScopeLogs[1].LogRecords
+	ass.Len(db, 1)
+	ass.Equal(17, db[0].SeverityNumber, "ERROR 对应 SeverityNumber 17")
+	ass.Equal(testTraceID, db[0].TraceID, "slog 应该从 context 中获取 span")
+	ass.Contains(db[0].Attributes, map[string]any{"key": "rows", "value": map[string]any{"intValue": "3"}})
+	ass.Contains(db[0].Attributes, map[string]any{"key": "table", "value": map[string]any{"stringValue": "orders"}})
+}
+
+func TestOTLPProto(t *testing.T) {
+	ass := assert.New(t)
+
+	server, bodies := fakeOTLPReceiver(t, "application/x-protobuf")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.HTTP).
+		SetHTTP(&HTTPConfig{
+			URL:           server.URL + "/v1/logs",
+			Format:        LogHTTPFormat.OTLPProto,
+			FlushInterval: time.Hour,
+		}).
+		BuildE()
+	ass.Nil(err, err)
+
+	ctx := ContextWithSpan(context.Background(), testTraceID, testSpanID)
+	slog.New(NewSlogHandler(l)).WarnContext(ctx, "disk almost full", "ratio", 0.95, "ok", true)
+	l.Close()
+
+	ass.Len(bodies(), 1)
+	req := decodeProto(t, bodies()[0])
+	resourceLogs := decodeProto(t, req[1][0].([]byte))
+
+	resource := decodeProto(t, resourceLogs[1][0].([]byte))
+	attr := decodeProto(t, resource[1][0].([]byte))
+	ass.Equal("service.name", string(attr[1][0].([]byte)))
+	ass.Equal(filepath.Base(os.Args[0]), string(decodeProto(t, attr[2][0].([]byte))[1][0].([]byte)), "默认 service.name 为程序名称")
+
+	scopeLogs := decodeProto(t, resourceLogs[2][0].([]byte))
+	ass.Equal("yi-logger", string(decodeProto(t, scopeLogs[1][0].([]byte))[1][0].([]byte)))
+
+	record := decodeProto(t, scopeLogs[2][0].([]byte))
+	ass.NotZero(record[1][0], "time_unix_nano 不能为空")
+	ass.Equal(uint64(13), record[2][0], "WARN 对应 SeverityNumber 13")
+	ass.Equal("WARN", string(record[3][0].([]byte)))
+	ass.Equal("disk almost full", string(decodeProto(t, record[5][0].([]byte))[1][0].([]byte)))
+	ass.Equal(testTraceID, hex.EncodeToString(record[9][0].([]byte)))
+	ass.Equal(testSpanID, hex.EncodeToString(record[10][0].([]byte)))
+
+	values := make(map[string]map[int][]any)
+	for _, raw := range record[6] {
+		kv := decodeProto(t, raw.([]byte))
+		values[string(kv[1][0].([]byte))] = decodeProto(t, kv[2][0].([]byte))
+	}
+	ass.Equal(math.Float64bits(0.95), values["ratio"][4][0], "浮点数使用 double_value")
+	ass.Equal(uint64(1), values["ok"][2][0], "布尔值使用 bool_value")
+	ass.Contains(values, "code.lineno")
+}
+
+// decodeProto 解析一层 protobuf 消息，varint 和 fixed64 返回 uint64，长度分隔的字段返回 []byte
+func decodeProto(t *testing.T, b []byte) map[int][]any {
+	fields := make(map[int][]any)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("invalid protobuf tag")
+		}
+		b = b[n:]
+
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[field] = append(fields[field], v)
+		case 1:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[field] = append(fields[field], b[:size])
+			b = b[size:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
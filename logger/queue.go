@@ -0,0 +1,278 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Chentyit/yi-logger/file_op"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// queued 异步输出目标等待发送的日志
+type queued struct {
+	entry  *yiLogEntry // 原始日志，诊断日志也有
+	data   []byte      // 按照输出目标的格式转换后的内容
+	notice int         // 诊断日志报告的丢弃条数，为 0 时是普通日志
+}
+
+// pos
+// @author Tianyi
+// @description 日志在预写队列中的位置
+func (item queued) pos() file_op.SpoolPos {
+	if item.entry == nil {
+		return file_op.SpoolPos{}
+	}
+	return item.entry.pos
+}
+
+// asyncQueue
+// @author Tianyi
+// @description 异步输出目标 (syslog、HTTP、TCP/UDP、Fluentd) 共用的发送缓冲区，writer 协程放入日志，
+//				发送协程取出发送，超过上限时丢弃最早的日志，发送失败的日志放回头部，
+//				丢弃的条数在之后的发送中作为一条诊断日志发送，关闭时仍未报告的输出到标准错误
+type asyncQueue struct {
+	name   string                                     // 输出目标的地址，用于诊断日志
+	limit  int                                        // 缓冲区上限
+	bytes  bool                                       // 上限按照字节数计算，否则按照条数
+	encode func(entry *yiLogEntry, log []byte) []byte // 转换为发送的内容
+	notice func(msg string) (*yiLogEntry, []byte)     // 生成诊断日志，为 nil 时不发送
+	ack    ackFunc                                    // 开启预写队列时确认日志送达
+
+	mu       sync.Mutex
+	items    []queued // 等待发送的日志
+	size     int      // 等待发送的日志占用的大小
+	dropped  int      // 缓冲区满、无法发送或者关闭时没有发送而丢弃的日志条数
+	reported int      // 已经报告过的丢弃条数
+
+	signal chan struct{}
+	exitCh chan struct{}
+	doneCh chan struct{}
+}
+
+// newAsyncQueue
+// @author Tianyi
+// @description 创建发送缓冲区，bytes 为 true 时 limit 是字节数，否则是日志条数
+func newAsyncQueue(name string, limit int, bytes bool, encode func(entry *yiLogEntry, log []byte) []byte) *asyncQueue {
+	return &asyncQueue{
+		name:   name,
+		limit:  limit,
+		bytes:  bytes,
+		encode: encode,
+		signal: make(chan struct{}, 1),
+		exitCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// sizeOf
+// @author Tianyi
+// @description 一条日志占用的大小
+func (q *asyncQueue) sizeOf(item queued) int {
+	if q.bytes {
+		return len(item.data)
+	}
+	return 1
+}
+
+// push
+// @author Tianyi
+// @description 放入一条日志，超过上限时丢弃最早的日志，返回缓冲区中的日志条数
+func (q *asyncQueue) push(entry *yiLogEntry, log []byte) int {
+	item := queued{entry: entry, data: q.encode(entry, log)}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, item)
+	q.size += q.sizeOf(item)
+	q.trim()
+	return len(q.items)
+}
+
+// notify
+// @author Tianyi
+// @description 通知发送协程有新的日志
+func (q *asyncQueue) notify() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// take
+// @author Tianyi
+// @description 取出最多 max 条日志，max 小于等于 0 时取出全部，
+//				有还没有报告的丢弃条数时在最前面加上一条诊断日志
+func (q *asyncQueue) take(max int) []queued {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := len(q.items)
+	if max > 0 && n > max {
+		n = max
+	}
+	if n == 0 {
+		return nil
+	}
+	items := q.items[:n:n]
+	q.items = q.items[n:]
+	if len(q.items) == 0 {
+		q.items = nil
+	}
+	for _, item := range items {
+		q.size -= q.sizeOf(item)
+	}
+
+	if n := q.dropped - q.reported; n > 0 && q.notice != nil {
+		entry, log := q.notice(fmt.Sprintf("dropped %d entries while %s was unavailable", n, q.name))
+		items = append([]queued{{entry: entry, data: q.encode(entry, log), notice: n}}, items...)
+	}
+	return items
+}
+
+// sent
+// @author Tianyi
+// @description 通知日志的发送结果，诊断日志送达后记为已报告
+func (q *asyncQueue) sent(items []queued, err error) {
+	for _, item := range items {
+		if item.notice > 0 {
+			if err == nil {
+				q.mu.Lock()
+				q.reported += item.notice
+				q.mu.Unlock()
+			}
+			continue
+		}
+		if q.ack != nil {
+			q.ack(item.pos(), err)
+		}
+	}
+}
+
+// drop
+// @author Tianyi
+// @description 丢弃无法发送的日志 (例如超过数据报的大小限制)，计入丢弃条数
+func (q *asyncQueue) drop(items []queued) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.discard(items, errDiscarded)
+}
+
+// discard
+// @author Tianyi
+// @description 丢弃日志并计数，err 为通知预写队列的原因，诊断日志不计数，需要持有 mu
+func (q *asyncQueue) discard(items []queued, err error) {
+	for _, item := range items {
+		if item.notice > 0 {
+			continue
+		}
+		q.dropped++
+		if q.ack != nil {
+			q.ack(item.pos(), err)
+		}
+	}
+}
+
+// requeue
+// @author Tianyi
+// @description 将未发送的日志放回缓冲区头部，仍然受缓冲区上限限制，诊断日志不放回，下次发送时重新生成
+func (q *asyncQueue) requeue(items []queued) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := make([]queued, 0, len(items)+len(q.items))
+	for _, item := range items {
+		if item.notice == 0 {
+			kept = append(kept, item)
+			q.size += q.sizeOf(item)
+		}
+	}
+	q.items = append(kept, q.items...)
+	q.trim()
+}
+
+// trim
+// @author Tianyi
+// @description 超过缓冲区上限时丢弃最早的日志，至少保留一条，需要持有 mu
+func (q *asyncQueue) trim() {
+	for q.size > q.limit && len(q.items) > 1 {
+		q.size -= q.sizeOf(q.items[0])
+		q.discard(q.items[:1], errDiscarded)
+		q.items = q.items[1:]
+	}
+}
+
+// queue
+// @author Tianyi
+// @description 获取发送缓冲区，用于设置诊断日志
+func (q *asyncQueue) queue() *asyncQueue {
+	return q
+}
+
+func (q *asyncQueue) setAck(ack ackFunc) bool {
+	q.ack = ack
+	return true
+}
+
+// run
+// @author Tianyi
+// @description 发送协程，有日志时调用 flush，失败后按照指数退避重试，关闭前最后尝试发送一次
+func (q *asyncQueue) run(flush func() error, minBackoff, maxBackoff time.Duration) {
+	defer close(q.doneCh)
+
+	backoff := minBackoff
+	for {
+		select {
+		case <-q.signal:
+		case <-q.exitCh:
+			_ = flush()
+			return
+		}
+
+		for flush() != nil {
+			select {
+			case <-time.After(backoff):
+			case <-q.exitCh:
+				return
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		backoff = minBackoff
+	}
+}
+
+// shutdown
+// @author Tianyi
+// @description 停止发送协程并等待退出，之后调用 release
+func (q *asyncQueue) shutdown() {
+	close(q.exitCh)
+	<-q.doneCh
+	q.release()
+}
+
+// release
+// @author Tianyi
+// @description 发送协程退出后丢弃还没有发送的日志，已经无法在输出目标中报告的丢弃条数输出到标准错误
+func (q *asyncQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.discard(q.items, errDropped)
+	q.items = nil
+	q.size = 0
+	if n := q.dropped - q.reported; n > 0 {
+		fmt.Fprintf(os.Stderr, "yi-logger: dropped %d entries for %s\n", n, q.name)
+		q.reported = q.dropped
+	}
+}
+
+// tooLarge
+// @author Tianyi
+// @description 是否因为超过数据报的大小限制而发送失败
+func tooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
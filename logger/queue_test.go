@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAsyncQueueNotice(t *testing.T) {
+	ass := assert.New(t)
+
+	q := newAsyncQueue("collector", 2, false, func(_ *yiLogEntry, log []byte) []byte { return log })
+	q.notice = func(msg string) (*yiLogEntry, []byte) {
+		return nil, []byte(msg)
+	}
+	for _, msg := range []string{"1", "2", "3"} {
+		q.push(nil, []byte(msg))
+	}
+	ass.Equal(1, q.dropped, "超过上限时应该丢弃最早的日志")
+
+	// 发送失败时诊断日志不放回缓冲区，下次发送时重新生成
+	items := q.take(0)
+	ass.Len(items, 3)
+	ass.Equal("dropped 1 entries while collector was unavailable", string(items[0].data))
+	q.requeue(items)
+	ass.Len(q.items, 2)
+
+	items = q.take(1)
+	ass.Len(items, 2, "诊断日志不计入取出的条数")
+	q.sent(items, nil)
+	ass.Equal(1, q.reported, "诊断日志送达后应该记为已报告")
+	ass.Len(q.take(0), 1, "已经报告过的丢弃条数不应该再次报告")
+
+	// 关闭时没有发送的日志计入丢弃条数，并输出到标准错误
+	q.push(nil, []byte("4"))
+	q.release()
+	ass.Equal(2, q.dropped)
+	ass.Equal(q.dropped, q.reported)
+	ass.Nil(q.items)
+}
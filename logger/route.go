@@ -0,0 +1,335 @@
+package logger
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"github.com/Chentyit/yi-logger/file_op"
+	"strings"
+	"sync"
+)
+
+// Route
+// @author Tianyi
+// @description 路由规则，满足所有条件的日志额外写入 File，例如 WARN 以上的日志写入 error.log，
+//				File 中可以使用 {字段名} 按照字段值写入不同的文件 (例如 logs/{tenant}.log)，
+//				没有该字段的日志不匹配，字段值中的路径分隔符会被替换为 '_'
+type Route struct {
+	File      string                                              // 日志文件，支持和 YiLogConfig.File 相同的路径模板
+	MinLevel  Level                                               // 最低日志级别 (默认: TraceLevel -> 所有级别)
+	Logger    string                                              // logger 名称，同时匹配 "名称." 开头的子 Logger (默认: 所有 Logger)
+	Field     string                                              // 日志必须包含的字段
+	Value     string                                              // 字段值，为空时只要求包含该字段
+	Match     func(level Level, name string, fields []Field) bool // 自定义条件
+	Exclusive bool                                                // 匹配后不再写入主输出，至少写入一个路由文件即视为送达
+
+	MaxSize    int   // 每个日志最大容量 (默认: 和 YiLogConfig 相同，单位: MB)
+	MaxBackups int   // 最多保存记录个数 (默认: 和 YiLogConfig 相同)
+	MaxAge     int   // 做多保存天数 (默认: 和 YiLogConfig 相同)
+	Compress   *bool // 是否需要压缩日志文件 (默认: 和 YiLogConfig 相同)
+}
+
+// route 解析后的路由规则
+type route struct {
+	Route
+	vars     []string // File 中引用的字段名
+	compress bool     // 是否需要压缩日志文件
+}
+
+// match
+// @author Tianyi
+// @description 判断日志是否满足路由条件
+func (r *route) match(entry *yiLogEntry) bool {
+	if entry.lvl < r.MinLevel {
+		return false
+	}
+	if len(r.Logger) != 0 && entry.Name != r.Logger && !strings.HasPrefix(entry.Name, r.Logger+".") {
+		return false
+	}
+	if len(r.Field) != 0 {
+		value, ok := fieldValue(entry.Fields, r.Field)
+		if !ok || (len(r.Value) != 0 && value != r.Value) {
+			return false
+		}
+	}
+	if r.Match != nil && !r.Match(entry.lvl, entry.Name, entry.Fields) {
+		return false
+	}
+	return true
+}
+
+// path
+// @author Tianyi
+// @description 使用字段值替换 File 中的 {字段名}，缺少字段时返回 false
+func (r *route) path(entry *yiLogEntry) (string, bool) {
+	path := r.File
+	for _, name := range r.vars {
+		value, ok := fieldValue(entry.Fields, name)
+		if !ok {
+			return "", false
+		}
+		path = strings.ReplaceAll(path, "{"+name+"}", sanitizePathValue(value))
+	}
+	return path, true
+}
+
+// routeSink
+// @author Tianyi
+// @description 按照路由规则将日志写入不同的文件，固定路径的文件一直打开，
+//				按照字段值生成的文件超过 MaxOpenFiles 时关闭最久没有写入的文件
+type routeSink struct {
+	cfg     *YiLogConfig
+	main    sink
+	routes  []*route
+	maxOpen int
+	quota   *file_op.Quota     // 和主日志共享的总容量限制
+	repair  file_op.RepairFunc // 修复文件末尾不完整的 Json 之后调用
+	ack     ackFunc            // 主输出目标异步确认时，只写入路由文件的日志在这里确认
+
+	static  map[string]*file_op.FileOp // 固定路径的文件
+	dynamic map[string]*list.Element   // 按照字段值生成的文件，值为 lru 中的元素
+	lru     *list.List                 // 最近写入的文件在前面
+	closed  sync.WaitGroup             // 已经关闭的文件在后台压缩历史日志
+}
+
+// routeFile lru 中保存的文件
+type routeFile struct {
+	path string
+	fo   *file_op.FileOp
+}
+
+// newRouteSink
+// @author Tianyi
+// @description 解析路由规则并构建输出目标
+func newRouteSink(cfg *YiLogConfig, main sink, quota *file_op.Quota, repair file_op.RepairFunc) (*routeSink, error) {
+	s := &routeSink{
+		cfg:     cfg,
+		main:    main,
+		quota:   quota,
+		repair:  repair,
+		maxOpen: cfg.MaxOpenFiles,
+		static:  make(map[string]*file_op.FileOp),
+		dynamic: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	if s.maxOpen <= 0 {
+		s.maxOpen = 64
+	}
+
+	for i := range cfg.Routes {
+		r := &route{Route: cfg.Routes[i]}
+		if len(r.File) == 0 {
+			return nil, errors.New("route file is required")
+		}
+		if r.MinLevel > LogLevel.PanicLevel {
+			return nil, fmt.Errorf("invalid route level: %d", r.MinLevel)
+		}
+		r.vars = routeVars(r.File)
+		if r.MaxSize <= 0 {
+			r.MaxSize = cfg.MaxSize
+		}
+		if r.MaxBackups <= 0 {
+			r.MaxBackups = cfg.MaxBackups
+		}
+		if r.MaxAge <= 0 {
+			r.MaxAge = cfg.MaxAge
+		}
+		r.compress = cfg.Compress
+		if r.Compress != nil {
+			r.compress = *r.Compress
+		}
+		s.routes = append(s.routes, r)
+	}
+	return s, nil
+}
+
+func (s *routeSink) write(entry *yiLogEntry, log []byte) error {
+	var err error
+	exclusive, written := false, false
+	for _, r := range s.routes {
+		if !r.match(entry) {
+			continue
+		}
+		path, ok := r.path(entry)
+		if !ok {
+			continue
+		}
+		if e := s.file(r, path).Write(log); e != nil {
+			if err == nil {
+				err = e
+			}
+		} else {
+			written = true
+		}
+		exclusive = exclusive || r.Exclusive
+	}
+
+	// 只写入路由文件的日志，部分路由文件写入失败时不再重新发送，避免重复写入已经成功的文件
+	if exclusive {
+		if !written {
+			return err
+		}
+		if s.ack != nil {
+			s.ack(entry.pos, nil)
+		}
+		return nil
+	}
+	if e := s.main.write(entry, log); e != nil {
+		return e
+	}
+	return err
+}
+
+// file
+// @author Tianyi
+// @description 获取路径对应的文件，不存在时创建
+func (s *routeSink) file(r *route, path string) *file_op.FileOp {
+	if len(r.vars) == 0 {
+		fo, ok := s.static[path]
+		if !ok {
+			fo = newFileOp(s.cfg, path, r.MaxSize, r.MaxBackups, r.MaxAge, r.compress, s.quota, s.repair)
+			s.static[path] = fo
+		}
+		return fo
+	}
+
+	if elem, ok := s.dynamic[path]; ok {
+		s.lru.MoveToFront(elem)
+		return elem.Value.(*routeFile).fo
+	}
+
+	// 超过上限时关闭最久没有写入的文件
+	for s.lru.Len() >= s.maxOpen {
+		oldest := s.lru.Back()
+		rf := oldest.Value.(*routeFile)
+		s.release(rf.fo)
+		s.lru.Remove(oldest)
+		delete(s.dynamic, rf.path)
+	}
+
+	fo := newFileOp(s.cfg, path, r.MaxSize, r.MaxBackups, r.MaxAge, r.compress, s.quota, s.repair)
+	s.dynamic[path] = s.lru.PushFront(&routeFile{path: path, fo: fo})
+	return fo
+}
+
+func (s *routeSink) close() error {
+	for _, fo := range s.static {
+		_ = fo.Close()
+		fo.WaitCompress()
+	}
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		s.release(e.Value.(*routeFile).fo)
+	}
+	s.closed.Wait()
+	return s.main.close()
+}
+
+// release
+// @author Tianyi
+// @description 关闭按照字段值生成的文件，不等待历史日志压缩完成，关闭输出目标时统一等待
+func (s *routeSink) release(fo *file_op.FileOp) {
+	_ = fo.Close()
+	s.closed.Add(1)
+	go func() {
+		defer s.closed.Done()
+		fo.WaitCompress()
+	}()
+}
+
+func (s *routeSink) reopen() error {
+	var err error
+	if r, ok := s.main.(reopener); ok {
+		err = r.reopen()
+	}
+	for _, fo := range s.static {
+		if e := fo.Reopen(); e != nil && err == nil {
+			err = e
+		}
+	}
+	// 按照字段值生成的文件直接关闭，下次写入时重新打开
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		s.release(e.Value.(*routeFile).fo)
+	}
+	s.lru.Init()
+	s.dynamic = make(map[string]*list.Element)
+	return err
+}
+
+func (s *routeSink) setAck(ack ackFunc) bool {
+	if a, ok := s.main.(acker); ok && a.setAck(ack) {
+		s.ack = ack
+		return true
+	}
+	return false
+}
+
+func (s *routeSink) sync() error {
+	var err error
+	if r, ok := s.main.(syncer); ok {
+		err = r.sync()
+	}
+	for _, fo := range s.static {
+		if e := fo.Sync(); e != nil && err == nil {
+			err = e
+		}
+	}
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		if e := elem.Value.(*routeFile).fo.Sync(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// routeVars
+// @author Tianyi
+// @description 获取路径中引用的字段名，date 和 service 由路径模板处理
+func routeVars(path string) []string {
+	var vars []string
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			return vars
+		}
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			return vars
+		}
+		name := path[start+1 : start+end]
+		if len(name) != 0 && name != "date" && name != "service" {
+			vars = append(vars, name)
+		}
+		path = path[start+end+1:]
+	}
+}
+
+// fieldValue
+// @author Tianyi
+// @description 获取字段值的字符串形式
+func fieldValue(fields []Field, key string) (string, bool) {
+	for _, f := range fields {
+		if f.Key == key {
+			if s, ok := f.Value.(string); ok {
+				return s, true
+			}
+			return fmt.Sprint(f.Value), true
+		}
+	}
+	return "", false
+}
+
+// sanitizePathValue
+// @author Tianyi
+// @description 字段值作为路径的一部分时替换路径分隔符和模板字符，避免写到日志目录之外
+func sanitizePathValue(value string) string {
+	if len(value) == 0 || value == "." || value == ".." {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '%', '{', '}', 0:
+			return '_'
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"github.com/Chentyit/yi-logger/file_op"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(dir, "app.log")).
+		SetRoutes(
+			Route{File: filepath.Join(dir, "error.log"), MinLevel: LogLevel.WarnLevel},
+			Route{File: filepath.Join(dir, "tenants", "{tenant}.log")},
+			Route{File: filepath.Join(dir, "audit.log"), Logger: "audit", Exclusive: true},
+		).
+		SetMaxOpenFiles(2).
+		BuildE()
+	ass.Nil(err, err)
+
+	sl := slog.New(NewSlogHandler(l))
+	l.Info("started")
+	l.Error("failed")
+	sl.Info("order a", "tenant", "acme")
+	sl.Info("order b", "tenant", "globex")
+	sl.Info("order c", "tenant", "initech")
+	sl.Info("order d", "tenant", "acme")
+	sl.Info("escape", "tenant", "../../etc")
+	l.Named("audit").Info("login")
+	l.Named("audit.db").Warn("drop table")
+
+	ass.LessOrEqual(l.sink.(*routeSink).lru.Len(), 2, "打开的文件数不能超过 MaxOpenFiles")
+	l.Close()
+
+	read := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		ass.Nil(err, err)
+		return string(content)
+	}
+
+	app := read("app.log")
+	ass.Equal(7, strings.Count(app, "\n"), "主日志应该包含除 Exclusive 路由之外的所有日志")
+	ass.NotContains(app, "login")
+
+	errorLog := read("error.log")
+	ass.Equal(2, strings.Count(errorLog, "\n"), "error.log 只包含 WARN 以上的日志")
+	ass.Contains(errorLog, `"message":"failed"`)
+	ass.Contains(errorLog, `"message":"drop table"`)
+
+	acme := read(filepath.Join("tenants", "acme.log"))
+	ass.Contains(acme, `"message":"order a"`)
+	ass.Contains(acme, `"message":"order d"`, "关闭后重新打开的文件应该继续追加")
+	ass.Contains(read(filepath.Join("tenants", "globex.log")), `"message":"order b"`)
+	ass.Contains(read(filepath.Join("tenants", "initech.log")), `"message":"order c"`)
+	ass.Contains(read(filepath.Join("tenants", ".._.._etc.log")), `"message":"escape"`, "字段值中的路径分隔符应该被替换")
+
+	audit := read("audit.log")
+	ass.Contains(audit, `"message":"login"`)
+	ass.Contains(audit, `"message":"drop table"`, "子 Logger 也应该匹配")
+}
+
+func TestRoutesRequireFileOutput(t *testing.T) {
+	ass := assert.New(t)
+
+	_, err := BuildLoggerLink().SetRoutes(Route{File: "error.log"}).BuildE()
+	ass.NotNil(err, "控制台输出不支持路由")
+
+	_, err = BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(t.TempDir(), "app.log")).
+		SetRoutes(Route{}).
+		BuildE()
+	ass.NotNil(err, "路由规则必须设置文件")
+}
+
+func TestRouteCompress(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	off := false
+	cfg := &YiLogConfig{Compress: true, MaxSize: 10}
+	cfg.SetRoutes(
+		Route{File: filepath.Join(dir, "error.log")},
+		Route{File: filepath.Join(dir, "audit.log"), Compress: &off},
+	)
+	s, err := newRouteSink(cfg, nil, nil, nil)
+	ass.Nil(err, err)
+	ass.True(s.routes[0].compress, "没有设置 Compress 时应该和 YiLogConfig 相同")
+	ass.False(s.routes[1].compress)
+	ass.Equal(10, s.routes[0].MaxSize)
+}
+
+func TestRouteExclusivePartialFailure(t *testing.T) {
+	ass := assert.New(t)
+
+	// 第二个路由文件的目录是一个普通文件，写入失败
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	ass.Nil(os.WriteFile(blocker, nil, 0644))
+	cfg := &YiLogConfig{}
+	cfg.SetRoutes(
+		Route{File: filepath.Join(dir, "audit.log"), Exclusive: true},
+		Route{File: filepath.Join(blocker, "audit.log")},
+	)
+	main := func() sink {
+		return &fileSink{fo: newFileOp(cfg, filepath.Join(dir, "app.log"), 1, 0, 0, false, nil, nil)}
+	}
+	s, err := newRouteSink(cfg, main(), nil, nil)
+	ass.Nil(err, err)
+	defer s.close()
+
+	var acked []error
+	s.ack = func(pos file_op.SpoolPos, err error) {
+		acked = append(acked, err)
+	}
+	entry := buildLogEntry(cfg, LogLevel.InfoLevel, "login")
+	ass.Nil(s.write(entry, []byte("login\n")), "至少写入一个路由文件时视为送达")
+	ass.Equal([]error{nil}, acked)
+
+	// 所有路由文件都写入失败时返回错误
+	cfg.SetRoutes(Route{File: filepath.Join(blocker, "audit.log"), Exclusive: true})
+	s, err = newRouteSink(cfg, main(), nil, nil)
+	ass.Nil(err, err)
+	defer s.close()
+	ass.NotNil(s.write(entry, []byte("login\n")))
+	ass.NoFileExists(filepath.Join(dir, "app.log"), "Exclusive 路由的日志不应该写入主输出")
+}
@@ -0,0 +1,262 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// EntrySchema
+// @author Tianyi
+// @description 日志 Json 字段名称映射，字段名称为空时不输出该字段
+type EntrySchema struct {
+	Time           string   // 日志时间
+	TimeUnixNano   bool     // 日志时间输出为 unix 纳秒整数，不受 TimeEncoder 影响
+	Ts             string   // 日志时间 (unix 纳秒)，需要开启 EnableTs
+	Trace          string   // 文件路径
+	Line           string   // 文件行数
+	Caller         string   // 调用位置，格式为 "文件路径:行数"
+	Source         string   // 调用位置，格式为 {"file": 文件路径, "line": 行数}
+	Level          string   // 日志级别名称
+	Name           string   // logger 名称，为空的名称不输出
+	SeverityNumber string   // 日志级别数值 (OpenTelemetry SeverityNumber)
+	Message        string   // 日志信息
+	TraceID        string   // 链路追踪 trace_id，为空的值不输出
+	TracePrefix    string   // trace_id 的前缀，例如 GCP 需要 "projects/<PROJECT_ID>/traces/"
+	SpanID         string   // 链路追踪 span_id，为空的值不输出
+	Repeated       string   // 连续重复次数
+	FirstTime      string   // 重复日志首次出现时间
+	LastTime       string   // 重复日志最后出现时间
+	LevelNames     []string // 日志级别名称映射，为空时使用 TRACE, DEBUG ... PANIC
+	FieldPrefix    string   // 和保留字段或者之前的字段重名的结构化字段加上的前缀 (默认: "fields.")
+}
+
+// defaultFieldPrefix 没有设置 FieldPrefix 时重名字段加上的前缀
+const defaultFieldPrefix = "fields."
+
+// LogSchema 预置的字段名称映射
+var LogSchema = struct {
+	Default EntrySchema
+	ECS     EntrySchema // Elastic Common Schema
+	GCP     EntrySchema // GCP Cloud Logging 结构化日志，需要关联 Cloud Trace 时使用 GCPSchema 设置项目 ID
+	OTel    EntrySchema // OpenTelemetry 日志数据模型
+}{
+	Default: EntrySchema{
+		Time:        "time",
+		Ts:          "ts",
+		Trace:       "trace",
+		Line:        "line",
+		Level:       "level",
+		Name:        "logger",
+		Message:     "message",
+		TraceID:     "trace_id",
+		SpanID:      "span_id",
+		Repeated:    "repeated",
+		FirstTime:   "first_time",
+		LastTime:    "last_time",
+		FieldPrefix: "fields.",
+	},
+	ECS: EntrySchema{
+		Time:        "@timestamp",
+		Ts:          "event.sequence",
+		Trace:       "log.origin.file.name",
+		Line:        "log.origin.file.line",
+		Level:       "log.level",
+		Name:        "log.logger",
+		Message:     "message",
+		TraceID:     "trace.id",
+		SpanID:      "span.id",
+		Repeated:    "event.repeated",
+		FirstTime:   "event.start",
+		LastTime:    "event.end",
+		FieldPrefix: "labels.",
+	},
+	GCP: EntrySchema{
+		Time:        "time",
+		Ts:          "ts",
+		Source:      "logging.googleapis.com/sourceLocation",
+		Level:       "severity",
+		Name:        "logger",
+		Message:     "message",
+		TraceID:     "logging.googleapis.com/trace",
+		SpanID:      "logging.googleapis.com/spanId",
+		Repeated:    "repeated",
+		FirstTime:   "first_time",
+		LastTime:    "last_time",
+		LevelNames:  []string{"DEBUG", "DEBUG", "INFO", "WARNING", "ERROR", "CRITICAL"},
+		FieldPrefix: "labels.",
+	},
+	OTel: EntrySchema{
+		Time:           "timeUnixNano",
+		TimeUnixNano:   true,
+		Ts:             "observedTimeUnixNano",
+		Trace:          "code.filepath",
+		Line:           "code.lineno",
+		Level:          "SeverityText",
+		SeverityNumber: "SeverityNumber",
+		Name:           "otel.scope.name",
+		Message:        "Body",
+		TraceID:        "TraceId",
+		SpanID:         "SpanId",
+		Repeated:       "repeated",
+		FirstTime:      "first_time",
+		LastTime:       "last_time",
+		FieldPrefix:    "attributes.",
+	},
+}
+
+// GCPSchema
+// @author Tianyi
+// @description GCP Cloud Logging 字段名称映射，trace_id 输出为 projects/<projectID>/traces/<trace_id>，
+//				Cloud Logging 只有这种格式才能关联到 Cloud Trace
+func GCPSchema(projectID string) EntrySchema {
+	schema := LogSchema.GCP
+	schema.TracePrefix = "projects/" + projectID + "/traces/"
+	return schema
+}
+
+// otelSeverity 日志级别对应的 OpenTelemetry SeverityNumber
+var otelSeverity = []int{
+	0: 1,  // TRACE
+	1: 5,  // DEBUG
+	2: 9,  // INFO
+	3: 13, // WARN
+	4: 17, // ERROR
+	5: 21, // FATAL
+}
+
+// levelName
+// @author Tianyi
+// @description 获取日志级别在该映射下的名称
+func (schema *EntrySchema) levelName(entry *yiLogEntry) string {
+	if int(entry.lvl) < len(schema.LevelNames) {
+		return schema.LevelNames[entry.lvl]
+	}
+	return entry.Level
+}
+
+// fieldKey
+// @author Tianyi
+// @description 结构化字段和保留字段或者之前的字段重名时加上 FieldPrefix，直到不再重名，
+//				避免输出重复的 Json key (不同的后端对重复 key 的取值不一致)
+func (schema *EntrySchema) fieldKey(written []string, key string) string {
+	prefix := schema.FieldPrefix
+	if len(prefix) == 0 {
+		prefix = defaultFieldPrefix
+	}
+	for schema.reserved(key) || hasKey(written, key) {
+		key = prefix + key
+	}
+	return key
+}
+
+// reserved
+// @author Tianyi
+// @description 判断字段名称是否被映射使用
+func (schema *EntrySchema) reserved(key string) bool {
+	for _, k := range []string{schema.Time, schema.Ts, schema.Trace, schema.Line, schema.Caller, schema.Source,
+		schema.Level, schema.Name, schema.SeverityNumber, schema.Message, schema.TraceID, schema.SpanID,
+		schema.Repeated, schema.FirstTime, schema.LastTime} {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// hasKey
+// @author Tianyi
+// @description 判断字段名称是否已经输出
+func hasKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// encode
+// @author Tianyi
+// @description 按照字段名称映射将日志序列化为一行 Json，字段顺序固定
+func (schema *EntrySchema) encode(entry *yiLogEntry) []byte {
+	enc := &entryEncoder{}
+	enc.buf.WriteByte('{')
+
+	if schema.TimeUnixNano && !entry.at.IsZero() {
+		enc.field(schema.Time, entry.at.UnixNano())
+	} else {
+		enc.field(schema.Time, entry.DateTime)
+	}
+	if entry.Ts != 0 {
+		enc.field(schema.Ts, entry.Ts)
+	}
+	enc.field(schema.Trace, entry.Trace)
+	enc.field(schema.Line, entry.Line)
+	enc.field(schema.Caller, entry.Trace+":"+strconv.Itoa(entry.Line))
+	enc.field(schema.Source, struct {
+		File string `json:"file"`
+		Line int    `json:"line"`
+	}{entry.Trace, entry.Line})
+	enc.field(schema.Level, schema.levelName(entry))
+	enc.field(schema.SeverityNumber, otelSeverity[entry.lvl])
+	if len(entry.Name) != 0 {
+		enc.field(schema.Name, entry.Name)
+	}
+	enc.field(schema.Message, entry.Message)
+	if len(entry.TraceID) != 0 {
+		enc.field(schema.TraceID, schema.TracePrefix+entry.TraceID)
+		enc.field(schema.SpanID, entry.SpanID)
+	}
+	var keys []string
+	for _, f := range entry.Fields {
+		key := schema.fieldKey(keys, f.Key)
+		keys = append(keys, key)
+		enc.field(key, f.Value)
+	}
+	if entry.Repeated != 0 {
+		enc.field(schema.Repeated, entry.Repeated)
+		enc.field(schema.FirstTime, entry.FirstTime)
+		enc.field(schema.LastTime, entry.LastTime)
+	}
+
+	enc.buf.WriteByte('}')
+	return enc.buf.Bytes()
+}
+
+// entryEncoder
+// @author Tianyi
+// @description 按顺序拼接 Json 字段
+type entryEncoder struct {
+	buf   bytes.Buffer
+	count int
+}
+
+// field
+// @author Tianyi
+// @description 写入一个字段，字段名称为空时跳过
+func (enc *entryEncoder) field(key string, value any) {
+	if len(key) == 0 {
+		return
+	}
+	if enc.count > 0 {
+		enc.buf.WriteByte(',')
+	}
+	enc.count++
+
+	appendJSONString(&enc.buf, key)
+	enc.buf.WriteByte(':')
+	switch v := value.(type) {
+	case string:
+		appendJSONString(&enc.buf, v)
+		return
+	case error:
+		appendJSONString(&enc.buf, v.Error())
+		return
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(err.Error())
+	}
+	enc.buf.Write(v)
+}
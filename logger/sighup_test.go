@@ -0,0 +1,44 @@
+//go:build unix
+
+package logger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReopenOnSIGHUP(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetReopenOnSIGHUP(true).
+		BuildE()
+	ass.Nil(err, err)
+	defer l.Close()
+
+	l.Info("before rotate")
+	ass.Nil(l.Reopen())
+	ass.Nil(os.Rename(file, file+".1"))
+	ass.Nil(syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	// 收到信号后 writer 协程重新创建日志文件
+	ass.Eventually(func() bool {
+		_, err := os.Stat(file)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond, "收到 SIGHUP 后应该重新创建日志文件")
+
+	l.Info("after rotate")
+	ass.Nil(l.Reopen())
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	ass.True(strings.Contains(string(content), `"message":"after rotate"`))
+}
@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Chentyit/yi-logger/file_op"
+)
+
+// sink
+// @author Tianyi
+// @description 日志输出目标，只会在 writer 协程中调用，不需要考虑并发
+type sink interface {
+	// write 写入一条日志，log 是按照 Schema 序列化后的 Json
+	write(entry *yiLogEntry, log []byte) error
+	// close 关闭输出目标
+	close() error
+}
+
+// reopener
+// @author Tianyi
+// @description 支持重新打开的输出目标
+type reopener interface {
+	reopen() error
+}
+
+// buildSink
+// @author Tianyi
+// @description 根据输出方式构建输出目标
+func (logger *yiLogger) buildSink() error {
+	cfg := logger.cfg
+
+	// 主日志和路由规则写入的日志共享总容量
+	var quota *file_op.Quota
+	if cfg.MaxTotalSize > 0 {
+		quota = file_op.NewQuota(cfg.MaxTotalSize)
+	}
+
+	switch cfg.OutputWay {
+	case OutPut.File:
+		logger.fo = newFileOp(cfg, cfg.File, cfg.MaxSize, cfg.MaxBackups, cfg.MaxAge, cfg.Compress, quota, logger.repaired)
+		logger.sink = &fileSink{fo: logger.fo}
+	case OutPut.Syslog:
+		s, err := newSyslogSink(cfg.Syslog)
+		if err != nil {
+			return err
+		}
+		logger.sink = s
+	case OutPut.HTTP:
+		s, err := newHTTPSink(cfg.HTTP)
+		if err != nil {
+			return err
+		}
+		logger.sink = s
+	case OutPut.Network:
+		s, err := newNetSink(cfg.Network)
+		if err != nil {
+			return err
+		}
+		logger.sink = s
+	case OutPut.Fluent:
+		s, err := newFluentSink(cfg.Fluent)
+		if err != nil {
+			return err
+		}
+		logger.sink = s
+	default:
+		return fmt.Errorf("unsupported output way: %d", cfg.OutputWay)
+	}
+
+	// 异步输出目标丢弃日志后发送诊断日志
+	if q, ok := logger.sink.(interface{ queue() *asyncQueue }); ok {
+		q.queue().notice = logger.notice
+	}
+
+	if len(cfg.Routes) != 0 {
+		s, err := newRouteSink(cfg, logger.sink, quota, logger.repaired)
+		if err != nil {
+			_ = logger.sink.close()
+			return err
+		}
+		logger.sink = s
+	}
+	return nil
+}
+
+// newFileOp
+// @author Tianyi
+// @description 按照配置创建 FileOp，文件切分设置单独传入，路由规则可以使用自己的设置，
+//				quota 不为 nil 时和其他日志共享总容量，打开已有的文件时按照 FileRepair 修复末尾不完整的行，修复后调用 onRepair
+func newFileOp(cfg *YiLogConfig, path string, maxSize, maxBackups, maxAge int, compress bool,
+	quota *file_op.Quota, onRepair file_op.RepairFunc) *file_op.FileOp {
+	fo := file_op.CreateFileOp(path, maxSize, compress).
+		SetClock(cfg.Clock).
+		SetMaxBackups(maxBackups).
+		SetMaxAge(maxAge).
+		SetMaxLines(cfg.MaxLines).
+		SetRotateDaily(cfg.RotateDaily).
+		SetLayout(cfg.FileLayout)
+	switch cfg.FileRepair {
+	case LogFileRepair.Move:
+		fo.SetRepair(json.Valid, onRepair)
+	case LogFileRepair.Newline:
+		fo.SetRepairNewline(onRepair)
+	}
+	if quota != nil {
+		fo.SetQuota(quota)
+	}
+	if file_op.IsTemplate(path, "service") {
+		fo.SetTemplate(path, map[string]string{"service": cfg.Service})
+	}
+	if cfg.FileCheckInterval > 0 {
+		fo.SetCheckInterval(cfg.FileCheckInterval)
+	}
+	return fo
+}
+
+// fileSink
+// @author Tianyi
+// @description 输出到文件，文件切分和压缩由 FileOp 完成
+type fileSink struct {
+	fo *file_op.FileOp
+}
+
+func (s *fileSink) write(_ *yiLogEntry, log []byte) error {
+	return s.fo.Write(log)
+}
+
+func (s *fileSink) close() error {
+	err := s.fo.Close()
+	s.fo.WaitCompress()
+	return err
+}
+
+func (s *fileSink) reopen() error {
+	return s.fo.Reopen()
+}
+
+func (s *fileSink) sync() error {
+	return s.fo.Sync()
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// SlogHandler
+// @author Tianyi
+// @description slog.Handler 的实现，通过 slog 输出的日志和 yiLogger 走同样的流程
+//				(日志格式、输出方式、文件切分)，group 使用 '.' 拼接到字段名称上
+type SlogHandler struct {
+	logger *yiLogger
+	attrs  []Field // WithAttrs 添加的字段
+	prefix string  // WithGroup 添加的字段前缀
+}
+
+// NewSlogHandler
+// @author Tianyi
+// @description 创建 slog.Handler，使用方式: slog.New(logger.NewSlogHandler(l))
+func NewSlogHandler(l *yiLogger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// Enabled
+// @author Tianyi
+// @description 判断该等级的日志是否需要输出
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.enabled(fromSlogLevel(level))
+}
+
+// Handle
+// @author Tianyi
+// @description 将 slog.Record 转换成日志记录并输出
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	cfg := h.logger.cfg
+
+	now := r.Time
+	if now.IsZero() {
+		now = cfg.now()
+	}
+
+	trace, line := "???", 0
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		trace, line = frame.File, frame.Line
+	}
+
+	msg := formatMsg(cfg.KeepNewline, "%s", r.Message)
+	entry := newLogEntry(cfg, fromSlogLevel(r.Level), msg, now, trace, line)
+
+	fields := make([]Field, 0, len(h.attrs)+r.NumAttrs())
+	fields = append(fields, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+	entry.Fields = fields
+	h.logger.withSpan(ctx, entry)
+
+	h.logger.output(entry)
+	return nil
+}
+
+// WithAttrs
+// @author Tianyi
+// @description 返回带有额外字段的 Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = make([]Field, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(h2.attrs, h.attrs)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+// WithGroup
+// @author Tianyi
+// @description 返回带有字段前缀的 Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr
+// @author Tianyi
+// @description 将 slog.Attr 转换成字段，group 会被展开
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		// key 为空的 group 直接展开到当前层级
+		if len(a.Key) != 0 {
+			prefix = prefix + a.Key + "."
+		}
+		for _, ga := range attrs {
+			fields = appendAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+
+	return append(fields, Field{Key: prefix + a.Key, Value: a.Value.Any()})
+}
+
+// fromSlogLevel
+// @author Tianyi
+// @description 将 slog 的日志等级转换为 Level，slog 没有 PANIC 等级，
+//				ERROR 以上的日志都作为 ERROR 输出，避免程序退出
+func fromSlogLevel(level slog.Level) Level {
+	switch {
+	case level < slog.LevelDebug:
+		return LogLevel.TraceLevel
+	case level < slog.LevelInfo:
+		return LogLevel.DebugLevel
+	case level < slog.LevelWarn:
+		return LogLevel.InfoLevel
+	case level < slog.LevelError:
+		return LogLevel.WarnLevel
+	default:
+		return LogLevel.ErrorLevel
+	}
+}
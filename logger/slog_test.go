@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	ass := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "slog.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetLevel(LogLevel.DebugLevel).
+		BuildE()
+	ass.Nil(err, err)
+
+	sl := slog.New(NewSlogHandler(l))
+	sl.Debug("debug message", "user", "tianyi")
+	sl.Log(context.Background(), slog.LevelDebug-4, "trace message is filtered")
+	sl.With("service", "api").WithGroup("req").Warn("slow request",
+		"id", 7, slog.Group("db", "rows", 3), slog.Group("", "inline", true))
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	ass.Len(lines, 2, "日志行数错误")
+
+	var first, second map[string]any
+	ass.Nil(json.Unmarshal([]byte(lines[0]), &first))
+	ass.Nil(json.Unmarshal([]byte(lines[1]), &second))
+
+	ass.Equal("DEBUG", first["level"])
+	ass.Equal("tianyi", first["user"])
+	ass.True(strings.HasSuffix(first["trace"].(string), "slog_test.go"), "调用位置错误")
+
+	ass.Equal("WARN", second["level"])
+	ass.Equal("slow request", second["message"])
+	ass.Equal("api", second["service"])
+	ass.EqualValues(7, second["req.id"])
+	ass.EqualValues(3, second["req.db.rows"])
+	ass.Equal(true, second["req.inline"])
+}
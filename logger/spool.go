@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/Chentyit/yi-logger/file_op"
+	"sync"
+	"time"
+)
+
+// spoolCommitInterval 提交预写队列确认位置的间隔
+const spoolCommitInterval = time.Second
+
+// errDropped 异步输出目标在送达之前丢弃日志，例如关闭时还没有发送，重启后重新发送
+var errDropped = errors.New("log dropped before delivery")
+
+// errDiscarded 异步输出目标主动丢弃日志，例如缓冲区已满或者超过数据报大小限制，
+// 和可用空间不足时丢弃的日志一样视为已确认
+var errDiscarded = errors.New("log discarded")
+
+// SpoolConfig
+// @author Tianyi
+// @description 预写队列配置，开启后日志先追加到磁盘上的段文件中，再由 writer 协程
+//				发送到输出目标，输出目标确认送达后才会提交，进程崩溃或者发送失败后
+//				重启会重新发送未确认的日志
+type SpoolConfig struct {
+	Dir         string // 段文件保存目录
+	SegmentSize int    // 单个段文件最大容量 (默认: 64，单位: MB)
+	Retention   int    // 已确认的段文件最多保留个数 (默认: 0 -> 确认后立即删除)
+	Sync        bool   // 每条日志写入后是否调用 fsync，关闭时只能保证进程崩溃不丢日志
+}
+
+// spoolRecord
+// @author Tianyi
+// @description 预写队列中保存的日志记录
+type spoolRecord struct {
+	Entry *yiLogEntry `json:"e"`
+	Level Level       `json:"l"`
+	At    time.Time   `json:"a"`
+}
+
+// openSpool
+// @author Tianyi
+// @description 打开预写队列
+func openSpool(cfg *SpoolConfig) (*file_op.Spool, error) {
+	segmentSize := cfg.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = 64
+	}
+	sp, err := file_op.OpenSpool(cfg.Dir, int64(segmentSize)*1024*1024, cfg.Retention)
+	if err != nil {
+		return nil, err
+	}
+	return sp.SetSync(cfg.Sync), nil
+}
+
+// encodeSpoolRecord
+// @author Tianyi
+// @description 序列化日志记录
+func encodeSpoolRecord(entry *yiLogEntry) ([]byte, error) {
+	return json.Marshal(&spoolRecord{Entry: entry, Level: entry.lvl, At: entry.at})
+}
+
+// decodeSpoolRecord
+// @author Tianyi
+// @description 反序列化日志记录，数字保持原样，避免 unix 纳秒时间丢失精度
+func decodeSpoolRecord(data []byte) (*yiLogEntry, error) {
+	var record spoolRecord
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	entry := record.Entry
+	entry.lvl = record.Level
+	entry.at = record.At
+	return entry, nil
+}
+
+// ackFunc 异步输出目标确认日志送达 (err 为 nil) 或者发送失败时调用，pos 为零值时忽略
+type ackFunc func(pos file_op.SpoolPos, err error)
+
+// acker
+// @author Tianyi
+// @description 异步发送的输出目标，日志真正送达或者失败时通过 ack 通知，
+//				setAck 返回 false 时表示 write 返回即送达
+type acker interface {
+	setAck(ack ackFunc) bool
+}
+
+// spoolAck
+// @author Tianyi
+// @description 记录预写队列中日志的确认状态，只提交连续确认送达的日志，
+//				发送失败的日志阻止提交，直到之后的日志送达说明输出目标已经恢复，
+//				进程在此之前退出时，重启后从第一条未确认的日志重新发送
+type spoolAck struct {
+	async bool // 输出目标是否异步确认
+
+	mu      sync.Mutex
+	pending []spoolPending // 已经读取还没有提交的日志，按照读取顺序排列
+}
+
+// spoolPending 已经读取还没有提交的日志
+type spoolPending struct {
+	pos    file_op.SpoolPos
+	acked  bool
+	failed bool // 发送失败
+}
+
+// track
+// @author Tianyi
+// @description 记录一条从预写队列读取的日志
+func (a *spoolAck) track(pos file_op.SpoolPos) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, spoolPending{pos: pos})
+}
+
+// ack
+// @author Tianyi
+// @description 确认日志送达，err 不为 nil 时表示发送失败，主动丢弃的日志视为已确认，
+//				日志送达时之前发送失败的日志也视为已确认，可以在任意协程中调用
+func (a *spoolAck) ack(pos file_op.SpoolPos, err error) {
+	if pos.IsZero() {
+		return
+	}
+	if errors.Is(err, errDiscarded) || errors.Is(err, errLowFreeSpace) {
+		err = nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range a.pending {
+		if a.pending[i].pos != pos {
+			continue
+		}
+		if err != nil {
+			a.pending[i].failed = true
+			return
+		}
+		a.pending[i].acked = true
+		for j := 0; j < i; j++ {
+			if a.pending[j].failed {
+				a.pending[j].failed = false
+				a.pending[j].acked = true
+			}
+		}
+		return
+	}
+}
+
+// wrote
+// @author Tianyi
+// @description 日志写入输出目标之后调用，同步输出目标写入成功即送达，
+//				异步输出目标只在写入失败时确认，送达后由输出目标确认
+func (a *spoolAck) wrote(entry *yiLogEntry, err error) {
+	if err != nil || !a.async {
+		a.ack(entry.pos, err)
+	}
+}
+
+// committable
+// @author Tianyi
+// @description 移除连续确认送达的日志，返回可以提交的位置
+func (a *spoolAck) committable() (file_op.SpoolPos, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for n < len(a.pending) && a.pending[n].acked {
+		n++
+	}
+	if n == 0 {
+		return file_op.SpoolPos{}, false
+	}
+	pos := a.pending[n-1].pos
+	a.pending = a.pending[n:]
+	return pos, true
+}
+
+// commitSpool
+// @author Tianyi
+// @description 提交已经确认送达的日志
+func (logger *yiLogger) commitSpool() {
+	if pos, ok := logger.spoolAck.committable(); ok {
+		_ = logger.spool.CommitTo(pos)
+	}
+}
+
+// drainSpool
+// @author Tianyi
+// @description 读取预写队列中所有未处理的日志并交给 handle 处理，提交由 writer 协程定时完成
+func (logger *yiLogger) drainSpool(handle func(entry *yiLogEntry)) {
+	for {
+		data, ok, err := logger.spool.Next()
+		if err != nil || !ok {
+			break
+		}
+		pos := logger.spool.Pos()
+		logger.spoolAck.track(pos)
+		entry, err := decodeSpoolRecord(data)
+		if err != nil {
+			// 无法解析的记录不会再成功，直接确认
+			logger.spoolAck.ack(pos, nil)
+			continue
+		}
+		entry.pos = pos
+		handle(entry)
+	}
+}
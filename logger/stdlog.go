@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"log"
+	"strconv"
+	"strings"
+)
+
+// stdLogWriter
+// @author Tianyi
+// @description 将标准库 log.Logger 的输出转换成日志记录，log.Logger 使用 Llongfile
+//				输出调用位置 ("文件路径:行数: 日志信息")，这里再把调用位置解析出来，
+//				这样调用位置就是真正调用 log.Printf 的地方，而不是适配器本身
+type stdLogWriter struct {
+	logger *yiLogger
+	level  Level
+}
+
+// Write
+// @author Tianyi
+// @description log.Logger 每条日志只会调用一次 Write
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	if !w.logger.enabled(w.level) {
+		return len(p), nil
+	}
+
+	cfg := w.logger.cfg
+	trace, line, msg := parseStdLog(strings.TrimSuffix(string(p), "\n"))
+	msg = formatMsg(cfg.KeepNewline, "%s", msg)
+	w.logger.output(newLogEntry(cfg, w.level, msg, cfg.now(), trace, line))
+	return len(p), nil
+}
+
+// parseStdLog
+// @author Tianyi
+// @description 解析 "文件路径:行数: 日志信息"，解析失败时整行作为日志信息
+func parseStdLog(s string) (string, int, string) {
+	idx := strings.Index(s, ": ")
+	if idx < 0 {
+		return "???", 0, s
+	}
+
+	head := s[:idx]
+	colon := strings.LastIndex(head, ":")
+	if colon < 0 {
+		return "???", 0, s
+	}
+	line, err := strconv.Atoi(head[colon+1:])
+	if err != nil {
+		return "???", 0, s
+	}
+	return head[:colon], line, s[idx+2:]
+}
+
+// NewStdLog
+// @author Tianyi
+// @description 创建一个输出到 yiLogger 的 *log.Logger，所有日志都使用 level 等级输出，
+//				可以用于 http.Server.ErrorLog 等只接受 *log.Logger 的地方
+func NewStdLog(l *yiLogger, level Level) *log.Logger {
+	return log.New(&stdLogWriter{logger: l, level: level}, "", log.Llongfile)
+}
+
+// RedirectStdLog
+// @author Tianyi
+// @description 将标准库 log 包的全局输出重定向到 yiLogger (INFO 等级)，
+//				返回的函数用于恢复原来的输出
+func RedirectStdLog(l *yiLogger) func() {
+	flags, prefix, writer := log.Flags(), log.Prefix(), log.Writer()
+
+	log.SetFlags(log.Llongfile)
+	log.SetPrefix("")
+	log.SetOutput(&stdLogWriter{logger: l, level: LogLevel.InfoLevel})
+
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(writer)
+	}
+}
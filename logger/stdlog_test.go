@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStdLog(t *testing.T) {
+	ass := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "std.log")
+	l, err := BuildLoggerLink().SetOutput(OutPut.File).SetFile(file).BuildE()
+	ass.Nil(err, err)
+
+	stdLog := NewStdLog(l, LogLevel.ErrorLevel)
+	_, _, line, _ := runtime.Caller(0)
+	stdLog.Printf("http: TLS handshake error from %s", "127.0.0.1")
+
+	restore := RedirectStdLog(l)
+	log.Println("legacy\nmessage")
+	restore()
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	ass.Len(lines, 2, "日志行数错误")
+
+	var first, second map[string]any
+	ass.Nil(json.Unmarshal([]byte(lines[0]), &first))
+	ass.Nil(json.Unmarshal([]byte(lines[1]), &second))
+
+	ass.Equal("ERROR", first["level"])
+	ass.Equal("http: TLS handshake error from 127.0.0.1", first["message"])
+	ass.True(strings.HasSuffix(first["trace"].(string), "stdlog_test.go"), "调用位置错误")
+	ass.EqualValues(line+1, first["line"], "调用行数错误")
+
+	ass.Equal("INFO", second["level"])
+	ass.Equal("legacy message", second["message"])
+	ass.EqualValues(line+4, second["line"], "调用行数错误")
+}
+
+func TestParseStdLog(t *testing.T) {
+	ass := assert.New(t)
+
+	trace, line, msg := parseStdLog(`C:\app\main.go:12: error: boom`)
+	ass.Equal(`C:\app\main.go`, trace)
+	ass.Equal(12, line)
+	ass.Equal("error: boom", msg)
+
+	trace, line, msg = parseStdLog("no caller info")
+	ass.Equal("???", trace)
+	ass.Equal(0, line)
+	ass.Equal("no caller info", msg)
+}
@@ -0,0 +1,111 @@
+package logger
+
+// SyncPolicy 日志文件同步到磁盘 (fsync) 的策略
+type SyncPolicy byte
+
+// LogSync 同步策略选项
+var LogSync = struct {
+	Never    SyncPolicy // 不主动同步，由操作系统决定何时写入磁盘
+	Interval SyncPolicy // 每隔 SyncInterval 同步一次
+	Entries  SyncPolicy // 每写入 SyncEntries 条日志同步一次
+	Level    SyncPolicy // 写入 SyncLevel 及以上的日志后同步
+	Always   SyncPolicy // 每条日志写入后同步
+	Default  SyncPolicy
+}{0, 1, 2, 3, 4, 0}
+
+// syncer
+// @author Tianyi
+// @description 支持同步到磁盘的输出目标
+type syncer interface {
+	sync() error
+}
+
+// durability
+// @author Tianyi
+// @description 按照同步策略决定写入日志后是否同步，只会在 writer 协程中调用
+type durability struct {
+	policy  SyncPolicy
+	entries int   // Entries 策略下每多少条日志同步一次
+	level   Level // Level 策略下需要同步的最低日志级别
+	wait    bool  // 需要同步的日志是否等待同步完成后返回
+	count   int   // 上一次同步之后写入的日志条数
+}
+
+// newDurability
+// @author Tianyi
+// @description 根据配置创建同步策略
+func newDurability(cfg *YiLogConfig) *durability {
+	level := LogLevel.ErrorLevel
+	if cfg.SyncLevel != nil {
+		level = *cfg.SyncLevel
+	}
+	return &durability{
+		policy:  cfg.Sync,
+		entries: cfg.SyncEntries,
+		level:   level,
+		wait:    cfg.SyncWait,
+	}
+}
+
+// waits
+// @author Tianyi
+// @description 判断是否有日志需要等待同步完成
+func (d *durability) waits() bool {
+	return d.wait && (d.policy == LogSync.Level || d.policy == LogSync.Always)
+}
+
+// needWait
+// @author Tianyi
+// @description 判断该级别的日志是否需要等待同步完成，只有 Level 和 Always 策略支持等待
+func (d *durability) needWait(level Level) bool {
+	if !d.waits() {
+		return false
+	}
+	return d.policy == LogSync.Always || level >= d.level
+}
+
+// wrote
+// @author Tianyi
+// @description 记录写入一条日志，返回是否需要同步
+func (d *durability) wrote(level Level) bool {
+	d.count++
+	switch d.policy {
+	case LogSync.Entries:
+		return d.count >= d.entries
+	case LogSync.Level:
+		return level >= d.level
+	case LogSync.Always:
+		return true
+	}
+	return false
+}
+
+// dirty
+// @author Tianyi
+// @description 判断上一次同步之后是否写入过日志，用于 Interval 策略和关闭时同步
+func (d *durability) dirty() bool {
+	return d.policy != LogSync.Never && d.count > 0
+}
+
+// sync
+// @author Tianyi
+// @description 同步输出目标，不支持同步的输出目标直接返回
+func (logger *yiLogger) sync() error {
+	logger.durable.count = 0
+	if s, ok := logger.sink.(syncer); ok {
+		return s.sync()
+	}
+	return nil
+}
+
+// canSync
+// @author Tianyi
+// @description 判断主输出目标是否支持同步到磁盘，路由规则只会额外写入文件，以主输出目标为准
+func (logger *yiLogger) canSync() bool {
+	sink := logger.sink
+	if r, ok := sink.(*routeSink); ok {
+		sink = r.main
+	}
+	_, ok := sink.(syncer)
+	return ok
+}
@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDurability(t *testing.T) {
+	ass := assert.New(t)
+
+	d := &durability{policy: LogSync.Never}
+	ass.False(d.wrote(LogLevel.PanicLevel), "Never 策略不同步")
+	ass.False(d.dirty())
+
+	d = &durability{policy: LogSync.Entries, entries: 3}
+	ass.False(d.wrote(LogLevel.InfoLevel))
+	ass.False(d.wrote(LogLevel.InfoLevel))
+	ass.True(d.wrote(LogLevel.InfoLevel), "写入 3 条日志后同步")
+
+	d = &durability{policy: LogSync.Level, level: LogLevel.ErrorLevel, wait: true}
+	ass.False(d.wrote(LogLevel.WarnLevel))
+	ass.True(d.wrote(LogLevel.ErrorLevel), "ERROR 日志写入后同步")
+	ass.True(d.dirty(), "Interval 策略和关闭时需要同步")
+	ass.False(d.needWait(LogLevel.WarnLevel))
+	ass.True(d.needWait(LogLevel.ErrorLevel))
+
+	d = &durability{policy: LogSync.Interval, wait: true}
+	ass.False(d.wrote(LogLevel.PanicLevel))
+	ass.False(d.needWait(LogLevel.PanicLevel), "Interval 策略不支持等待")
+
+	d = &durability{policy: LogSync.Always}
+	ass.True(d.wrote(LogLevel.TraceLevel))
+	ass.False(d.needWait(LogLevel.PanicLevel), "没有开启同步模式时不等待")
+}
+
+func TestSyncWait(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "audit.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetDedup(true).
+		SetSync(LogSync.Level).
+		SetSyncWait(true).
+		BuildE()
+	ass.Nil(err, err)
+	defer l.Close()
+	ass.Equal(LogLevel.ErrorLevel, l.durable.level, "默认同步 ERROR 及以上的日志")
+
+	for i := 0; i < 3; i++ {
+		l.Error("audit %d", i)
+		// Error 返回时日志已经写入磁盘
+		content, err := os.ReadFile(file)
+		ass.Nil(err, err)
+		ass.Contains(string(content), fmt.Sprintf(`"message":"audit %d"`, i))
+	}
+
+	_, err = BuildLoggerLink().SetOutput(OutPut.File).SetFile(file).SetSync(SyncPolicy(9)).BuildE()
+	ass.NotNil(err, "同步策略错误时应该返回错误")
+}
+
+func TestSyncWaitConfig(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(dir, "audit.log")).
+		SetSync(LogSync.Level).
+		SetSyncLevel(LogLevel.TraceLevel).
+		BuildE()
+	ass.Nil(err, err)
+	ass.Equal(LogLevel.TraceLevel, l.durable.level, "明确设置的 TRACE 不应该被改成默认值")
+	l.Close()
+
+	_, err = BuildLoggerLink().
+		SetOutput(OutPut.Network).
+		SetNetwork(&NetworkConfig{Address: "127.0.0.1:1"}).
+		SetSync(LogSync.Always).
+		SetSyncWait(true).
+		BuildE()
+	ass.NotNil(err, "不支持同步的输出目标不能开启同步模式")
+}
+
+func TestSyncWaitError(t *testing.T) {
+	ass := assert.New(t)
+
+	dir := t.TempDir()
+	var failed []error
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(filepath.Join(dir, "audit.log")).
+		SetSync(LogSync.Always).
+		SetSyncWait(true).
+		SetMinFreeSpace(100, LogLevel.ErrorLevel).
+		SetOnSyncError(func(err error) {
+			failed = append(failed, err)
+		}).
+		BuildE()
+	ass.Nil(err, err)
+	defer l.Close()
+	l.guard.freeSpace = func(string) (uint64, error) {
+		return 0, nil
+	}
+
+	l.Info("dropped")
+	// Info 返回时已经通知调用方
+	ass.Len(failed, 1, "同步模式下丢弃的日志应该通知调用方")
+	ass.True(errors.Is(failed[0], errLowFreeSpace))
+
+	l.Error("kept")
+	ass.Len(failed, 1, "写入磁盘的日志不需要通知")
+}
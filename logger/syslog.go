@@ -0,0 +1,294 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyslogFormat syslog 消息格式
+type SyslogFormat byte
+
+// LogSyslogFormat syslog 消息格式选项
+var LogSyslogFormat = struct {
+	RFC5424 SyslogFormat
+	RFC3164 SyslogFormat
+	Default SyslogFormat
+}{0, 1, 0}
+
+// syslogSeverity 日志级别对应的 syslog severity
+var syslogSeverity = []int{
+	0: 7, // TRACE -> debug
+	1: 7, // DEBUG -> debug
+	2: 6, // INFO  -> informational
+	3: 4, // WARN  -> warning
+	4: 3, // ERROR -> error
+	5: 2, // PANIC -> critical
+}
+
+// SyslogConfig
+// @author Tianyi
+// @description syslog 输出配置
+type SyslogConfig struct {
+	Network    string        // 传输方式: udp、tcp、unix (默认: unix)
+	Address    string        // 地址 (默认: unix -> /dev/log)
+	Format     SyslogFormat  // 消息格式 (默认: RFC5424)
+	Facility   int           // facility 0~23 (默认: 1 -> user，kern 不允许应用使用)
+	AppName    string        // APP-NAME (默认: 程序名称)
+	Hostname   string        // HOSTNAME (默认: os.Hostname)
+	SDID       string        // 结构化数据 ID，日志的调用位置和字段都放在这里 (默认: fields@32473)
+	BufferSize int           // 断开连接时最多缓存的字节数，超过后丢弃最早的日志 (默认: 1MB)
+	MinBackoff time.Duration // 第一次重连的等待时间，之后每次翻倍 (默认: 100ms)
+	MaxBackoff time.Duration // 重连最长等待时间 (默认: 30s)
+}
+
+// syslogSink
+// @author Tianyi
+// @description 输出到 syslog，发送在单独的协程中完成，writer 协程只负责放入缓冲区，
+//				连接断开时按照指数退避重连，期间的日志缓存在内存中，TCP 和 unix 流式 socket
+//				使用 RFC 6587 octet-counting 分帧，UDP 和 unix 数据报 socket 每条日志一个数据报
+type syslogSink struct {
+	*asyncQueue
+	cfg    SyslogConfig
+	pid    int
+	conn   net.Conn // 只在发送协程中使用
+	stream bool     // conn 是否为流式连接，流式连接需要分帧
+}
+
+// newSyslogSink
+// @author Tianyi
+// @description 构建 syslog 输出目标并启动发送协程，连接在第一次发送时建立
+func newSyslogSink(cfg *SyslogConfig) (*syslogSink, error) {
+	var c SyslogConfig
+	if cfg != nil {
+		c = *cfg
+	}
+
+	if len(c.Network) == 0 {
+		c.Network = "unix"
+	}
+	if c.Network == "unix" && len(c.Address) == 0 {
+		c.Address = "/dev/log"
+	}
+	switch c.Network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", c.Network)
+	}
+	if len(c.Address) == 0 {
+		return nil, errors.New("syslog address is required")
+	}
+	if c.Format > LogSyslogFormat.RFC3164 {
+		return nil, fmt.Errorf("invalid syslog format: %d", c.Format)
+	}
+	if c.Facility <= 0 || c.Facility > 23 {
+		c.Facility = 1
+	}
+	if len(c.AppName) == 0 {
+		c.AppName = filepath.Base(os.Args[0])
+	}
+	if len(c.Hostname) == 0 {
+		c.Hostname, _ = os.Hostname()
+	}
+	if len(c.SDID) == 0 {
+		c.SDID = "fields@32473"
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1024 * 1024
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 100 * time.Millisecond
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 30 * time.Second
+	}
+
+	s := &syslogSink{
+		cfg: c,
+		pid: os.Getpid(),
+	}
+	s.asyncQueue = newAsyncQueue(c.Address, c.BufferSize, true, func(entry *yiLogEntry, _ []byte) []byte {
+		return s.format(entry)
+	})
+	go s.run(s.flush, c.MinBackoff, c.MaxBackoff)
+	return s, nil
+}
+
+// dial
+// @author Tianyi
+// @description 建立连接，/dev/log 一般是数据报 socket，失败时再尝试流式 socket
+func (s *syslogSink) dial() error {
+	var err error
+	if s.cfg.Network == "unix" {
+		s.conn, err = net.Dial("unixgram", s.cfg.Address)
+		if err == nil {
+			s.stream = false
+			return nil
+		}
+	}
+	s.conn, err = net.DialTimeout(s.cfg.Network, s.cfg.Address, 5*time.Second)
+	if err != nil {
+		s.conn = nil
+		return err
+	}
+	s.stream = !strings.HasPrefix(s.cfg.Network, "udp")
+	return nil
+}
+
+func (s *syslogSink) write(entry *yiLogEntry, log []byte) error {
+	s.push(entry, log)
+	s.notify()
+	return nil
+}
+
+func (s *syslogSink) close() error {
+	s.shutdown()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// flush
+// @author Tianyi
+// @description 逐条发送缓冲区中的消息，失败时断开连接，未发送的消息放回缓冲区
+func (s *syslogSink) flush() error {
+	msgs := s.take(0)
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var err error
+	if s.conn == nil {
+		err = s.dial()
+	}
+	sent := 0
+	for err == nil && sent < len(msgs) {
+		err = s.send(msgs[sent].data)
+		switch {
+		case err == nil:
+			s.sent(msgs[sent:sent+1], nil)
+			sent++
+		case !s.stream && tooLarge(err):
+			// 超过数据报大小限制的消息重试也无法发送，丢弃后继续发送后面的消息
+			s.drop(msgs[sent : sent+1])
+			err = nil
+			sent++
+		}
+	}
+	if err != nil {
+		if s.conn != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+		}
+		s.requeue(msgs[sent:])
+	}
+	return err
+}
+
+// send
+// @author Tianyi
+// @description 发送一条消息，流式连接需要在消息前加上长度
+func (s *syslogSink) send(msg []byte) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if s.stream {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// format
+// @author Tianyi
+// @description 按照配置的格式生成 syslog 消息
+func (s *syslogSink) format(entry *yiLogEntry) []byte {
+	pri := s.cfg.Facility*8 + syslogSeverity[entry.lvl]
+	var buf bytes.Buffer
+
+	if s.cfg.Format == LogSyslogFormat.RFC3164 {
+		// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+		fmt.Fprintf(&buf, "<%d>%s %s %s[%d]: %s", pri, entry.at.Format(time.Stamp),
+			s.cfg.Hostname, s.cfg.AppName, s.pid, entry.Message)
+		for _, f := range entry.Fields {
+			fmt.Fprintf(&buf, " %s=%v", f.Key, f.Value)
+		}
+		return buf.Bytes()
+	}
+
+	// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID PARAM="VALUE"...] MSG
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - ", pri, entry.at.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeader(s.cfg.Hostname, 255), syslogHeader(s.cfg.AppName, 48), s.pid)
+	buf.WriteByte('[')
+	buf.WriteString(syslogName(s.cfg.SDID))
+	writeSDParam(&buf, "trace", entry.Trace)
+	writeSDParam(&buf, "line", strconv.Itoa(entry.Line))
+	for _, f := range entry.Fields {
+		writeSDParam(&buf, f.Key, fmt.Sprint(f.Value))
+	}
+	buf.WriteString("] ")
+	buf.WriteString(entry.Message)
+	return buf.Bytes()
+}
+
+// writeSDParam
+// @author Tianyi
+// @description 写入结构化数据参数，值中的 '"'、'\' 和 ']' 需要转义
+func writeSDParam(buf *bytes.Buffer, name, value string) {
+	buf.WriteByte(' ')
+	buf.WriteString(syslogName(name))
+	buf.WriteString(`="`)
+	for _, r := range value {
+		if r == '"' || r == '\\' || r == ']' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	buf.WriteByte('"')
+}
+
+// syslogName
+// @author Tianyi
+// @description SD-ID 和 PARAM-NAME 只能是除 '='、' '、']'、'"' 以外的可见 ASCII 字符，
+//				最长 32 个字符，不合法的字符替换为 '_'
+func syslogName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if c <= 32 || c >= 127 || c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// syslogHeader
+// @author Tianyi
+// @description 头部字段只能是可见 ASCII 字符，为空时使用 '-'
+func syslogHeader(value string, maxLen int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,251 @@
+package logger
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	ass := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	defer pc.Close()
+
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.Syslog).
+		SetSyslog(&SyslogConfig{
+			Network:  "udp",
+			Address:  pc.LocalAddr().String(),
+			Facility: 16,
+			AppName:  "yi-app",
+			Hostname: "host1",
+		}).
+		BuildE()
+	ass.Nil(err, err)
+
+	l.Warn("disk %s", "full")
+	l.Close()
+
+	buf := make([]byte, 2048)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	ass.Nil(err, err)
+
+	// local0 (16) * 8 + warning (4) = 132
+	pattern := `^<132>1 \d{4}-\d{2}-\d{2}T\S+ host1 yi-app \d+ - \[fields@32473 trace="\S+syslog_test.go" line="\d+"\] disk full$`
+	ass.Regexp(regexp.MustCompile(pattern), string(buf[:n]))
+}
+
+func TestSyslogTCPReconnect(t *testing.T) {
+	ass := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	defer ln.Close()
+
+	s, err := newSyslogSink(&SyslogConfig{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		Format:   LogSyslogFormat.RFC3164,
+		AppName:  "yi-app",
+		Hostname: "host1",
+	})
+	ass.Nil(err, err)
+	defer s.close()
+
+	cfg := &YiLogConfig{}
+	entry := buildLogEntry(cfg, LogLevel.ErrorLevel, "first")
+	entry.Fields = []Field{{"tenant", "a"}}
+	ass.Nil(s.write(entry, nil))
+
+	conn, err := ln.Accept()
+	ass.Nil(err, err)
+	msg := readOctetCounted(t, bufio.NewReader(conn))
+	// user (1) * 8 + error (3) = 11
+	ass.Regexp(regexp.MustCompile(`^<11>\w{3} [ \d]\d \d{2}:\d{2}:\d{2} host1 yi-app\[\d+\]: first tenant=a$`), msg)
+
+	// 服务端断开连接后，写入失败时需要重新连接
+	_ = conn.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+
+	var second net.Conn
+	deadline := time.After(5 * time.Second)
+	for second == nil {
+		_ = s.write(buildLogEntry(cfg, LogLevel.InfoLevel, "second"), nil)
+		select {
+		case second = <-accepted:
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("没有重新连接")
+		}
+	}
+	defer second.Close()
+	ass.True(strings.HasSuffix(readOctetCounted(t, bufio.NewReader(second)), ": second"))
+}
+
+func TestSyslogUnixStream(t *testing.T) {
+	ass := assert.New(t)
+
+	// 只支持流式 socket 的 syslog 服务
+	path := filepath.Join(t.TempDir(), "log.sock")
+	ln, err := net.Listen("unix", path)
+	ass.Nil(err, err)
+	defer ln.Close()
+
+	s, err := newSyslogSink(&SyslogConfig{
+		Address:  path,
+		Format:   LogSyslogFormat.RFC3164,
+		AppName:  "yi-app",
+		Hostname: "host1",
+	})
+	ass.Nil(err, err)
+	defer s.close()
+
+	cfg := &YiLogConfig{}
+	ass.Nil(s.write(buildLogEntry(cfg, LogLevel.InfoLevel, "first"), nil))
+	ass.Nil(s.write(buildLogEntry(cfg, LogLevel.InfoLevel, "second"), nil))
+
+	conn, err := ln.Accept()
+	ass.Nil(err, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	ass.True(strings.HasSuffix(readOctetCounted(t, r), ": first"), "流式 socket 的消息需要分帧")
+	ass.True(strings.HasSuffix(readOctetCounted(t, r), ": second"), "流式 socket 的消息需要分帧")
+}
+
+func TestSyslogBuffer(t *testing.T) {
+	ass := assert.New(t)
+
+	// 找一个没有监听的端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	s, err := newSyslogSink(&SyslogConfig{
+		Network:    "tcp",
+		Address:    addr,
+		BufferSize: 1024,
+		MinBackoff: time.Hour,
+	})
+	ass.Nil(err, err)
+
+	cfg := &YiLogConfig{}
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		ass.Nil(s.write(buildLogEntry(cfg, LogLevel.InfoLevel, strings.Repeat("x", 100)), nil))
+	}
+	ass.Less(time.Since(start), time.Second, "连接失败时写入不应该阻塞")
+
+	s.mu.Lock()
+	ass.LessOrEqual(s.size, 1024, "缓冲区超过限制")
+	ass.Greater(s.dropped, 0, "缓冲区满时应该丢弃最早的日志")
+	s.mu.Unlock()
+
+	// 等待重连时关闭不应该阻塞
+	start = time.Now()
+	ass.Nil(s.close())
+	ass.Less(time.Since(start), time.Second, "关闭时不应该等待重连")
+}
+
+func readOctetCounted(t *testing.T, r *bufio.Reader) string {
+	size, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, n)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func TestSyslogDroppedNotice(t *testing.T) {
+	ass := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.Syslog).
+		SetSyslog(&SyslogConfig{
+			Network:    "tcp",
+			Address:    addr,
+			BufferSize: 1,
+			MinBackoff: 10 * time.Millisecond,
+			MaxBackoff: 50 * time.Millisecond,
+		}).
+		BuildE()
+	ass.Nil(err, err)
+
+	// 服务端不可用时缓冲区只保留最后一条日志
+	l.Info("first")
+	l.Info("second")
+	l.Info("third")
+	s := l.sink.(*syslogSink)
+	ass.Eventually(func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.dropped == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ln, err = net.Listen("tcp", addr)
+	ass.Nil(err, err)
+	defer ln.Close()
+	conn, err := ln.Accept()
+	ass.Nil(err, err)
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	ass.Contains(readOctetCounted(t, r), "dropped 2 entries while "+addr+" was unavailable", "重新连接后应该先报告丢弃的日志")
+	ass.True(strings.HasSuffix(readOctetCounted(t, r), "third"))
+	l.Close()
+}
+
+func TestSyslogUDPTooLarge(t *testing.T) {
+	ass := assert.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	ass.Nil(err, err)
+	defer pc.Close()
+
+	s, err := newSyslogSink(&SyslogConfig{Network: "udp", Address: pc.LocalAddr().String()})
+	ass.Nil(err, err)
+	defer s.close()
+
+	// 超过 UDP 数据报大小限制的消息丢弃，不影响后面的消息
+	cfg := &YiLogConfig{}
+	ass.Nil(s.write(buildLogEntry(cfg, LogLevel.InfoLevel, strings.Repeat("x", 70000)), nil))
+	ass.Nil(s.write(buildLogEntry(cfg, LogLevel.InfoLevel, "small"), nil))
+
+	buf := make([]byte, 2048)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	ass.Nil(err, err)
+	ass.True(strings.HasSuffix(string(buf[:n]), " small"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ass.Equal(1, s.dropped, "过大的消息应该计入丢弃条数")
+}
@@ -0,0 +1,168 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// defaultMaxLineSize LineWriter 默认的单行最大长度
+const defaultMaxLineSize = 64 * 1024
+
+// levelPrefixes 日志等级前缀，用于从文本中识别日志等级
+var levelPrefixes = []struct {
+	prefix string
+	level  Level
+}{
+	{"TRACE", LogLevel.TraceLevel},
+	{"DEBUG", LogLevel.DebugLevel},
+	{"INFO", LogLevel.InfoLevel},
+	{"WARNING", LogLevel.WarnLevel},
+	{"WARN", LogLevel.WarnLevel},
+	{"ERROR", LogLevel.ErrorLevel},
+	{"ERR", LogLevel.ErrorLevel},
+	{"FATAL", LogLevel.PanicLevel},
+	{"PANIC", LogLevel.PanicLevel},
+}
+
+// LineWriter
+// @author Tianyi
+// @description 实现 io.Writer，将写入的内容按行切分，每行作为一条日志输出，
+//				可以用于子进程的 Stdout/Stderr 等只接受 io.Writer 的地方，
+//				没有换行的内容会先缓存，超过 maxLineSize 时强制作为一行输出
+type LineWriter struct {
+	mu          sync.Mutex
+	logger      *yiLogger
+	level       Level  // 日志等级
+	buf         []byte // 未结束的行
+	maxLineSize int    // 单行最大长度 (默认: 64KB)
+	detectLevel bool   // 是否根据 "ERROR:"、"[WARN]" 等前缀识别日志等级
+}
+
+// Writer
+// @author Tianyi
+// @description 创建一个以 level 等级输出日志的 io.Writer
+func (logger *yiLogger) Writer(level Level) *LineWriter {
+	return &LineWriter{
+		logger:      logger,
+		level:       level,
+		maxLineSize: defaultMaxLineSize,
+	}
+}
+
+// SetMaxLineSize
+// @author Tianyi
+// @description 设置单行最大长度
+func (w *LineWriter) SetMaxLineSize(maxLineSize int) *LineWriter {
+	if maxLineSize > 0 {
+		w.maxLineSize = maxLineSize
+	}
+	return w
+}
+
+// SetDetectLevel
+// @author Tianyi
+// @description 设置是否根据前缀识别日志等级
+func (w *LineWriter) SetDetectLevel(detect bool) *LineWriter {
+	w.detectLevel = detect
+	return w
+}
+
+// Write
+// @author Tianyi
+// @description 写入内容，每遇到一个换行输出一条日志
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		w.emit(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+	}
+
+	// 没有换行的内容超过最大长度，强制输出
+	for len(w.buf) >= w.maxLineSize {
+		w.emit(w.buf[:w.maxLineSize])
+		w.buf = w.buf[w.maxLineSize:]
+	}
+
+	// 缓冲区已经全部输出时释放底层数组
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Flush
+// @author Tianyi
+// @description 将未结束的行作为一条日志输出
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) != 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+// Close
+// @author Tianyi
+// @description 输出未结束的行，不会关闭 Logger
+func (w *LineWriter) Close() error {
+	w.Flush()
+	return nil
+}
+
+// emit
+// @author Tianyi
+// @description 将一行内容作为日志输出
+func (w *LineWriter) emit(line []byte) {
+	msg := strings.TrimSuffix(string(line), "\r")
+	if len(msg) == 0 {
+		return
+	}
+
+	level := w.level
+	if w.detectLevel {
+		level, msg = detectLevel(msg, level)
+	}
+	if !w.logger.enabled(level) {
+		return
+	}
+
+	cfg := w.logger.cfg
+	msg = formatMsg(cfg.KeepNewline, "%s", msg)
+	w.logger.output(newLogEntry(cfg, level, msg, cfg.now(), "???", 0))
+}
+
+// detectLevel
+// @author Tianyi
+// @description 根据 "ERROR:"、"ERROR "、"[ERROR]" 等前缀识别日志等级 (不区分大小写)，
+//				识别成功时返回去掉前缀的日志信息
+func detectLevel(msg string, def Level) (Level, string) {
+	s := msg
+	bracket := strings.HasPrefix(s, "[")
+	if bracket {
+		s = s[1:]
+	}
+
+	for _, lp := range levelPrefixes {
+		if len(s) <= len(lp.prefix) || !strings.EqualFold(s[:len(lp.prefix)], lp.prefix) {
+			continue
+		}
+		rest := s[len(lp.prefix):]
+		switch {
+		case bracket && rest[0] == ']':
+			return lp.level, strings.TrimLeft(rest[1:], " :")
+		case !bracket && (rest[0] == ':' || rest[0] == ' '):
+			return lp.level, strings.TrimLeft(rest, " :")
+		}
+	}
+	return def, msg
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	ass := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "writer.log")
+	l, err := BuildLoggerLink().
+		SetOutput(OutPut.File).
+		SetFile(file).
+		SetLevel(LogLevel.InfoLevel).
+		BuildE()
+	ass.Nil(err, err)
+
+	w := l.Writer(LogLevel.InfoLevel).SetDetectLevel(true).SetMaxLineSize(12)
+	_, _ = fmt.Fprint(w, "starting\r\nERROR: disk ")
+	_, _ = fmt.Fprint(w, "full\n[warn] retry\ndebug: filtered\n0123456789")
+	ass.Nil(w.Close())
+	l.Close()
+
+	content, err := os.ReadFile(file)
+	ass.Nil(err, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+
+	var entries []map[string]any
+	for _, line := range lines {
+		var entry map[string]any
+		ass.Nil(json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	ass.Len(entries, 5, "日志行数错误")
+
+	// "ERROR: disk " 达到 12 个字节时会被强制输出，"0123456789" 在 Close 时输出
+	expected := [][2]string{
+		{"INFO", "starting"},
+		{"ERROR", "disk"},
+		{"INFO", "full"},
+		{"WARN", "retry"},
+		{"INFO", "0123456789"},
+	}
+	for i, e := range expected {
+		ass.Equal(e[0], entries[i]["level"], "日志等级错误")
+		ass.Equal(e[1], strings.TrimSpace(entries[i]["message"].(string)), "日志信息错误")
+	}
+}
+
+func TestDetectLevel(t *testing.T) {
+	ass := assert.New(t)
+
+	level, msg := detectLevel("Error: boom", LogLevel.InfoLevel)
+	ass.Equal(LogLevel.ErrorLevel, level)
+	ass.Equal("boom", msg)
+
+	level, msg = detectLevel("[WARNING] slow", LogLevel.InfoLevel)
+	ass.Equal(LogLevel.WarnLevel, level)
+	ass.Equal("slow", msg)
+
+	level, msg = detectLevel("information", LogLevel.DebugLevel)
+	ass.Equal(LogLevel.DebugLevel, level)
+	ass.Equal("information", msg)
+}